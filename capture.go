@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Execute runs the capture command.
+func (c *CaptureCommand) Execute(args []string) error {
+	if err := setTopics(args); err != nil {
+		return err
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Transaction markers are kept to know the offsets they take, e.g. the
+	// last one of a partition, but aren't captured.
+	sourceOpts := config.Source
+	if sourceOpts != nil {
+		sourceOpts = append(slices.Clone(sourceOpts), kgo.KeepControlRecords())
+	}
+
+	client, adminClient, err := getClients(sourceOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create source Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	sourceTopics, err := config.getTopics(rootCtx, adminClient)
+	if err != nil {
+		return fmt.Errorf("failed to get source topics: %w", err)
+	}
+
+	if err := mirror.CheckTopics(sourceTopics, config.Topics); err != nil {
+		return err
+	}
+
+	watermarks, err := config.getWatermarks(rootCtx, adminClient, mirror.ReadsCommitted(client), mirror.TopicNames(config.Topics)...)
+	if err != nil {
+		return fmt.Errorf("failed to get source watermarks: %w", err)
+	}
+
+	out, err := openOutput(c.Output)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+
+	buffered := bufio.NewWriter(out)
+	encoder := json.NewEncoder(buffered)
+
+	// remaining holds, per partition, the end offset at startup that still
+	// has to be reached before the capture is complete: its last stable
+	// offset when reading committed records, which an open transaction
+	// holds back.
+	remaining := map[string]map[int32]int64{}
+	for _, topic := range config.Topics {
+		for partition, wm := range watermarks[topic.Name] {
+			offset, ok := topic.OffsetOf(partition)
+			if !ok || wm.Resolve(offset) >= wm.End {
+				continue
+			}
+			if remaining[topic.Name] == nil {
+				remaining[topic.Name] = map[int32]int64{}
+			}
+			remaining[topic.Name][partition] = wm.End
+		}
+	}
+
+	client.AddConsumePartitions(mirror.ConsumePartitions(sourceTopics, config.Topics))
+
+	captured := 0
+	for c.Follow || len(remaining) > 0 {
+		fetches := client.PollFetches(rootCtx)
+		if rootCtx.Err() != nil {
+			break
+		}
+		if err := fetches.Err(); err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			r := iter.Next()
+			if end, ok := remaining[r.Topic][r.Partition]; ok && r.Offset+1 >= end {
+				delete(remaining[r.Topic], r.Partition)
+				if len(remaining[r.Topic]) == 0 {
+					delete(remaining, r.Topic)
+				}
+			}
+			if r.Attrs.IsControl() {
+				continue
+			}
+
+			if err := encoder.Encode(mirror.NewCapturedRecord(r)); err != nil {
+				return fmt.Errorf("failed to write record: %w", err)
+			}
+			captured++
+
+			if c.MaxRecords > 0 && captured >= c.MaxRecords {
+				remaining = nil
+				c.Follow = false
+				break
+			}
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+
+	slog.Info("Captured records", slog.Int("records", captured))
+	return nil
+}
+
+// Execute runs the replay command.
+func (c *ReplayCommand) Execute(_ []string) error {
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	in, err := openInput(c.Input)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	// Captured records carry their partition, so they are produced to
+	// exactly the same partition instead of being re-partitioned by key.
+	sinkOpts := append(slices.Clone(config.Sink), kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	if config.Sink == nil {
+		sinkOpts = nil
+	}
+
+	client, _, err := getClients(sinkOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create sink Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	var (
+		mu         sync.Mutex
+		produceErr error
+	)
+
+	replayed := 0
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	for {
+		var cr mirror.CapturedRecord
+		if err := decoder.Decode(&cr); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return &mirror.ConfigError{Err: fmt.Errorf("failed to decode record %d: %w", replayed+1, err)}
+		}
+
+		client.Produce(rootCtx, cr.Record(), func(_ *kgo.Record, err error) {
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if produceErr == nil {
+				produceErr = err
+			}
+		})
+		replayed++
+	}
+
+	if err := client.Flush(rootCtx); err != nil {
+		return &mirror.SinkWriteError{Err: fmt.Errorf("failed to flush records: %w", err)}
+	}
+
+	if produceErr != nil {
+		return &mirror.SinkWriteError{Err: fmt.Errorf("failed to produce records: %w", produceErr)}
+	}
+
+	slog.Info("Replayed records", slog.Int("records", replayed))
+	return nil
+}
+
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+
+	f, err := os.Create(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return f, nil
+}
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	return f, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
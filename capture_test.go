@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestCapture_TransactionMarkers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test against a fake cluster")
+	}
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "orders"))
+	if err != nil {
+		t.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	brokers := cluster.ListenAddrs()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.TransactionalID("producer"))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer producer.Close()
+
+	ctx := context.Background()
+	if err := producer.BeginTransaction(); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	for _, v := range []string{"v0", "v1"} {
+		if err := producer.ProduceSync(ctx, &kgo.Record{Topic: "orders", Value: []byte(v)}).FirstErr(); err != nil {
+			t.Fatalf("ProduceSync() error = %v", err)
+		}
+	}
+	if err := producer.EndTransaction(ctx, kgo.TryCommit); err != nil {
+		t.Fatalf("EndTransaction() error = %v", err)
+	}
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = Config{
+		Source:  []kgo.Opt{kgo.SeedBrokers(brokers...), kgo.FetchIsolationLevel(kgo.ReadCommitted())},
+		Timeout: 5 * time.Second,
+	}
+
+	// The partition ends with the commit marker rather than a record, yet
+	// the capture stops once it reached the high watermark.
+	output := filepath.Join(t.TempDir(), "orders.jsonl")
+	errs := make(chan error, 1)
+	go func() { errs <- (&CaptureCommand{Output: output}).Execute([]string{"orders@-2"}) }()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("capture did not stop at the high watermark")
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatalf("os.Open() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var got []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var cr mirror.CapturedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &cr); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		got = append(got, string(cr.Record().Value))
+	}
+	if want := []string{"v0", "v1"}; !slices.Equal(got, want) {
+		t.Errorf("captured records = %v, want %v", got, want)
+	}
+}
+
+func TestCapture_OpenTransaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test against a fake cluster")
+	}
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "orders"))
+	if err != nil {
+		t.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	brokers := cluster.ListenAddrs()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.ProduceSync(ctx, &kgo.Record{Topic: "orders", Value: []byte("v0")}).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	// A transaction still open at startup holds back the last stable offset.
+	producer, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.TransactionalID("producer"))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer producer.Close()
+
+	if err := producer.BeginTransaction(); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	if err := producer.ProduceSync(ctx, &kgo.Record{Topic: "orders", Value: []byte("v1")}).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = Config{
+		Source:  []kgo.Opt{kgo.SeedBrokers(brokers...), kgo.FetchIsolationLevel(kgo.ReadCommitted())},
+		Timeout: 5 * time.Second,
+	}
+
+	// The capture stops at the last stable offset, the high watermark is
+	// only reached once the transaction ends.
+	output := filepath.Join(t.TempDir(), "orders.jsonl")
+	errs := make(chan error, 1)
+	go func() { errs <- (&CaptureCommand{Output: output}).Execute([]string{"orders@-2"}) }()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("capture did not stop at the last stable offset")
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+
+	var got []string
+	for line := range strings.Lines(string(data)) {
+		var cr mirror.CapturedRecord
+		if err := json.Unmarshal([]byte(line), &cr); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		got = append(got, string(cr.Record().Value))
+	}
+	if want := []string{"v0"}; !slices.Equal(got, want) {
+		t.Errorf("captured records = %v, want %v", got, want)
+	}
+}
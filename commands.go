@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Execute runs the topics command.
+func (c *TopicsCommand) Execute(args []string) error {
+	rootCtx := context.Background()
+
+	clusterOpts := config.Source
+	if c.Side == "sink" {
+		clusterOpts = config.Sink
+	}
+
+	client, adminClient, err := getClients(clusterOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create %s Kafka client: %w", c.Side, err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(rootCtx, config.Timeout)
+	defer cancel()
+
+	var topics kadm.TopicDetails
+	if c.Internal {
+		topics, err = adminClient.ListTopicsWithInternal(ctx, args...)
+	} else {
+		topics, err = adminClient.ListTopics(ctx, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list %s topics: %w", c.Side, err)
+	}
+
+	if len(args) == 0 {
+		return printTopicList(os.Stdout, topics)
+	}
+
+	configs, err := adminClient.DescribeTopicConfigs(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("failed to describe %s topic configs: %w", c.Side, err)
+	}
+
+	return printTopicDetails(os.Stdout, args, topics, configs)
+}
+
+func printTopicList(w io.Writer, topics kadm.TopicDetails) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TOPIC\tPARTITIONS\tREPLICAS")
+	for _, dt := range topics.Sorted() {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", dt.Topic, len(dt.Partitions), dt.Partitions.NumReplicas())
+	}
+	return tw.Flush()
+}
+
+func printTopicDetails(w io.Writer, names []string, topics kadm.TopicDetails, configs kadm.ResourceConfigs) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, name := range names {
+		if i > 0 {
+			_, _ = fmt.Fprintln(tw)
+		}
+
+		dt, ok := topics[name]
+		if !ok || dt.Err != nil {
+			_, _ = fmt.Fprintf(tw, "Topic: %s\tError: %v\n", name, dt.Err)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(tw, "Topic: %s\tID: %s\tPartitions: %d\n", name, dt.ID, len(dt.Partitions))
+		_, _ = fmt.Fprintln(tw, "PARTITION\tLEADER\tREPLICAS\tISR")
+		for _, pd := range dt.Partitions.Sorted() {
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%v\t%v\n", pd.Partition, pd.Leader, pd.Replicas, pd.ISR)
+		}
+
+		overrides := topicConfigOverrides(configs, name)
+		if len(overrides) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintln(tw, "CONFIG\tVALUE")
+		for _, key := range slices.Sorted(maps.Keys(overrides)) {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\n", key, overrides[key])
+		}
+	}
+	return tw.Flush()
+}
+
+// topicConfigOverrides returns the configs of a topic that are not broker
+// defaults.
+func topicConfigOverrides(configs kadm.ResourceConfigs, topic string) map[string]string {
+	out := map[string]string{}
+	for _, rc := range configs {
+		if rc.Name != topic || rc.Err != nil {
+			continue
+		}
+
+		for _, c := range rc.Configs {
+			if c.Source != kmsg.ConfigSourceDynamicTopicConfig || c.Sensitive {
+				continue
+			}
+			out[c.Key] = c.MaybeValue()
+		}
+	}
+	return out
+}
+
+// Execute runs the offsets command.
+func (c *OffsetsCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return &mirror.ConfigError{Err: fmt.Errorf("no topics specified")}
+	}
+
+	rootCtx := context.Background()
+
+	sourceClient, sourceAdminClient, err := getClients(config.Source)
+	if err != nil {
+		return fmt.Errorf("failed to create source Kafka client: %w", err)
+	}
+	defer sourceClient.Close()
+
+	sourceWatermarks, err := config.getWatermarks(rootCtx, sourceAdminClient, false, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get source watermarks: %w", err)
+	}
+
+	// The sink is optional here: without it only the source watermarks are shown.
+	var sinkWatermarks map[string]map[int32]mirror.Watermark
+	if config.Sink != nil {
+		sinkClient, sinkAdminClient, err := getClients(config.Sink)
+		if err != nil {
+			return fmt.Errorf("failed to create sink Kafka client: %w", err)
+		}
+		defer sinkClient.Close()
+
+		sinkWatermarks, err = config.getWatermarks(rootCtx, sinkAdminClient, false, args...)
+		if err != nil {
+			return fmt.Errorf("failed to get sink watermarks: %w", err)
+		}
+	}
+
+	return printOffsets(os.Stdout, args, sourceWatermarks, sinkWatermarks)
+}
+
+// Execute runs the offsets translate command.
+func (c *OffsetsTranslateCommand) Execute(args []string) error {
+	m, err := mirror.ReadOffsetMap(c.Map)
+	if err != nil {
+		return &mirror.ConfigError{Err: err}
+	}
+
+	return translateOffset(os.Stdout, m, args)
+}
+
+func translateOffset(w io.Writer, m *mirror.OffsetMap, args []string) error {
+	if len(args) != 3 {
+		return &mirror.ConfigError{Err: fmt.Errorf("expected TOPIC PARTITION OFFSET, got %q", args)}
+	}
+
+	topic := args[0]
+	partition, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil || partition < 0 {
+		return &mirror.ConfigError{Err: fmt.Errorf("invalid partition %q", args[1])}
+	}
+	offset, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil || offset < 0 {
+		return &mirror.ConfigError{Err: fmt.Errorf("invalid offset %q", args[2])}
+	}
+
+	sink, mirrored, ok := m.Translate(topic, int32(partition), offset)
+	if !ok {
+		return fmt.Errorf("partition %d of topic %q is not in the offset map", partition, topic)
+	}
+
+	// A record that wasn't mirrored, e.g. because it was sampled out, has no
+	// sink offset: the one of the next mirrored record is shown instead.
+	status := "yes"
+	if !mirrored {
+		status = "no, next mirrored record"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TOPIC\tPARTITION\tSOURCE-OFFSET\tSINK-OFFSET\tMIRRORED")
+	_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", topic, partition, offset, sink, status)
+	return tw.Flush()
+}
+
+// printOffsets writes the watermarks of topics on the source and, if sink is
+// set, on the sink. END-DIFF is the difference between their high watermarks,
+// not the lag of a mirror: sink offsets differ from source offsets whenever
+// a partition isn't mirrored from its beginning, e.g. from @-1.
+func printOffsets(w io.Writer, topics []string, source, sink map[string]map[int32]mirror.Watermark) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if sink == nil {
+		_, _ = fmt.Fprintln(tw, "TOPIC\tPARTITION\tSOURCE-START\tSOURCE-END")
+	} else {
+		_, _ = fmt.Fprintln(tw, "TOPIC\tPARTITION\tSOURCE-START\tSOURCE-END\tSINK-START\tSINK-END\tEND-DIFF")
+	}
+
+	for _, topic := range topics {
+		for _, partition := range slices.Sorted(maps.Keys(source[topic])) {
+			src := source[topic][partition]
+			if sink == nil {
+				_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", topic, partition, src.Start, src.End)
+				continue
+			}
+
+			dst, ok := sink[topic][partition]
+			if !ok {
+				_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t-\t-\t-\n", topic, partition, src.Start, src.End)
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", topic, partition, src.Start, src.End, dst.Start, dst.End, src.End-dst.End)
+		}
+	}
+	return tw.Flush()
+}
+
+// topicDifference is a single property of a topic that differs between the
+// source and the sink.
+type topicDifference struct {
+	Topic  string
+	Field  string
+	Source string
+	Sink   string
+}
+
+// Execute runs the diff command.
+func (c *DiffCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return &mirror.ConfigError{Err: fmt.Errorf("no topics specified")}
+	}
+
+	rootCtx := context.Background()
+
+	sourceClient, sourceAdminClient, err := getClients(config.Source)
+	if err != nil {
+		return fmt.Errorf("failed to create source Kafka client: %w", err)
+	}
+	defer sourceClient.Close()
+
+	sinkClient, sinkAdminClient, err := getClients(config.Sink)
+	if err != nil {
+		return fmt.Errorf("failed to create sink Kafka client: %w", err)
+	}
+	defer sinkClient.Close()
+
+	source, err := config.describeCluster(rootCtx, sourceAdminClient, args)
+	if err != nil {
+		return fmt.Errorf("failed to describe source topics: %w", err)
+	}
+
+	sink, err := config.describeCluster(rootCtx, sinkAdminClient, args)
+	if err != nil {
+		return fmt.Errorf("failed to describe sink topics: %w", err)
+	}
+
+	diffs := diffTopics(args, source, sink)
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintln(os.Stdout, "No differences")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TOPIC\tFIELD\tSOURCE\tSINK")
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Topic, d.Field, d.Source, d.Sink)
+	}
+	return tw.Flush()
+}
+
+// clusterTopics is what the diff command knows about topics on one cluster.
+type clusterTopics struct {
+	Topics     kadm.TopicDetails
+	Configs    kadm.ResourceConfigs
+	Watermarks map[string]map[int32]mirror.Watermark
+}
+
+func (cfg *Config) describeCluster(rootCtx context.Context, client *kadm.Client, topics []string) (clusterTopics, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, cfg.Timeout)
+	defer cancel()
+
+	details, err := client.ListTopics(ctx, topics...)
+	if err != nil {
+		return clusterTopics{}, fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	existing := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if details.Has(topic) {
+			existing = append(existing, topic)
+		}
+	}
+
+	out := clusterTopics{Topics: details}
+	if len(existing) == 0 {
+		return out, nil
+	}
+
+	out.Configs, err = client.DescribeTopicConfigs(ctx, existing...)
+	if err != nil {
+		return out, fmt.Errorf("failed to describe topic configs: %w", err)
+	}
+
+	out.Watermarks, err = cfg.getWatermarks(rootCtx, client, false, existing...)
+	if err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+func diffTopics(topics []string, source, sink clusterTopics) []topicDifference {
+	var diffs []topicDifference
+	add := func(topic, field, src, dst string) {
+		if src != dst {
+			diffs = append(diffs, topicDifference{Topic: topic, Field: field, Source: src, Sink: dst})
+		}
+	}
+
+	for _, topic := range topics {
+		srcExists, dstExists := source.Topics.Has(topic), sink.Topics.Has(topic)
+		add(topic, "exists", fmt.Sprint(srcExists), fmt.Sprint(dstExists))
+		if !srcExists || !dstExists {
+			continue
+		}
+
+		add(topic, "partitions", fmt.Sprint(len(source.Topics[topic].Partitions)), fmt.Sprint(len(sink.Topics[topic].Partitions)))
+
+		srcConfigs := topicConfigOverrides(source.Configs, topic)
+		dstConfigs := topicConfigOverrides(sink.Configs, topic)
+		keys := slices.Sorted(maps.Keys(srcConfigs))
+		for key := range dstConfigs {
+			if _, ok := srcConfigs[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			add(topic, "config "+key, orDash(srcConfigs, key), orDash(dstConfigs, key))
+		}
+
+		for _, partition := range slices.Sorted(maps.Keys(source.Watermarks[topic])) {
+			src := source.Watermarks[topic][partition]
+			dst, ok := sink.Watermarks[topic][partition]
+			if !ok {
+				continue
+			}
+			add(topic, fmt.Sprintf("records[%d]", partition), fmt.Sprint(src.End-src.Start), fmt.Sprint(dst.End-dst.Start))
+		}
+	}
+
+	return diffs
+}
+
+func orDash(m map[string]string, key string) string {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	return "-"
+}
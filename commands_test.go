@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestTopicConfigOverrides(t *testing.T) {
+	configs := kadm.ResourceConfigs{
+		{
+			Name: "orders",
+			Configs: []kadm.Config{
+				{Key: "cleanup.policy", Value: strPtr("compact"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+				{Key: "retention.ms", Value: strPtr("604800000"), Source: kmsg.ConfigSourceDefaultConfig},
+				{Key: "secret", Sensitive: true, Source: kmsg.ConfigSourceDynamicTopicConfig},
+			},
+		},
+		{
+			Name:    "payments",
+			Configs: []kadm.Config{{Key: "cleanup.policy", Value: strPtr("delete"), Source: kmsg.ConfigSourceDynamicTopicConfig}},
+		},
+	}
+
+	got := topicConfigOverrides(configs, "orders")
+	if len(got) != 1 {
+		t.Fatalf("topicConfigOverrides() returned %d configs, want 1: %v", len(got), got)
+	}
+	if got["cleanup.policy"] != "compact" {
+		t.Errorf("topicConfigOverrides() cleanup.policy = %q, want compact", got["cleanup.policy"])
+	}
+}
+
+func TestDiffTopics(t *testing.T) {
+	source := clusterTopics{
+		Topics: kadm.TopicDetails{
+			"orders": {Topic: "orders", Partitions: kadm.PartitionDetails{0: {}, 1: {}}},
+			"users":  {Topic: "users", Partitions: kadm.PartitionDetails{0: {}}},
+		},
+		Configs: kadm.ResourceConfigs{
+			{Name: "orders", Configs: []kadm.Config{{Key: "cleanup.policy", Value: strPtr("compact"), Source: kmsg.ConfigSourceDynamicTopicConfig}}},
+		},
+		Watermarks: map[string]map[int32]mirror.Watermark{
+			"orders": {0: {Start: 0, End: 10}, 1: {Start: 5, End: 10}},
+		},
+	}
+	sink := clusterTopics{
+		Topics: kadm.TopicDetails{
+			"orders": {Topic: "orders", Partitions: kadm.PartitionDetails{0: {}}},
+		},
+		Watermarks: map[string]map[int32]mirror.Watermark{
+			"orders": {0: {Start: 0, End: 10}},
+		},
+	}
+
+	got := diffTopics([]string{"orders", "users"}, source, sink)
+	want := []topicDifference{
+		{Topic: "orders", Field: "partitions", Source: "2", Sink: "1"},
+		{Topic: "orders", Field: "config cleanup.policy", Source: "compact", Sink: "-"},
+		{Topic: "users", Field: "exists", Source: "true", Sink: "false"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("diffTopics() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("diffTopics()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPrintOffsets(t *testing.T) {
+	source := map[string]map[int32]mirror.Watermark{
+		"orders": {1: {Start: 0, End: 20}, 0: {Start: 0, End: 10}},
+	}
+	sink := map[string]map[int32]mirror.Watermark{
+		"orders": {0: {Start: 0, End: 4}},
+	}
+
+	var buf bytes.Buffer
+	if err := printOffsets(&buf, []string{"orders"}, source, sink); err != nil {
+		t.Fatalf("printOffsets() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("printOffsets() printed %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if fields := strings.Fields(lines[0]); fields[len(fields)-1] != "END-DIFF" {
+		t.Errorf("printOffsets() header = %q, want the watermark difference last", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "orders 0 0 10 0 4 6" {
+		t.Errorf("printOffsets() partition 0 = %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "orders 1 0 20 - - -" {
+		t.Errorf("printOffsets() partition 1 = %q", lines[2])
+	}
+}
+
+func TestTranslateOffset(t *testing.T) {
+	// Source offsets 10-12 and 15 of orders/0 are at sink offsets 0-3.
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	data := `{"partitions": [{"topic": "orders", "partition": 0, "next": 4, "runs": [
+		{"source": 10, "sink": 0, "count": 3},
+		{"source": 15, "sink": 3, "count": 1}
+	]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mirror.ReadOffsetMap(path)
+	if err != nil {
+		t.Fatalf("ReadOffsetMap() error = %v", err)
+	}
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"orders", "0", "11"}, want: "orders 0 11 1 yes"},
+		{args: []string{"orders", "0", "13"}, want: "orders 0 13 3 no, next mirrored record"},
+		{args: []string{"orders", "0", "20"}, want: "orders 0 20 4 no, next mirrored record"},
+		{args: []string{"orders", "1", "0"}, wantErr: true},
+		{args: []string{"orders", "x", "0"}, wantErr: true},
+		{args: []string{"orders", "0", "-1"}, wantErr: true},
+		{args: []string{"orders", "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := translateOffset(&buf, m, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translateOffset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("translateOffset() printed %d lines, want 2:\n%s", len(lines), buf.String())
+			}
+			if got := strings.Join(strings.Fields(lines[1]), " "); got != tt.want {
+				t.Errorf("translateOffset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,11 +4,14 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"log/slog"
+	"os"
 
 	"github.com/jessevdk/go-flags"
+	"github.com/mortezaPRK/kmir/mirror"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/kversion"
 	"github.com/twmb/franz-go/pkg/sasl"
@@ -18,31 +21,48 @@ import (
 
 var config Config
 
-func initializeConfig() error {
+func run() error {
 	var opts Options
 	parser := flags.NewParser(&opts, flags.Default)
 	parser.NamespaceDelimiter = "-"
-	topics, err := parser.Parse()
-	if err != nil {
-		if !flags.WroteHelp(err) {
-			return fmt.Errorf("failed to parse flags: %w", err)
+	parser.SubcommandsOptional = true
+	parser.CommandHandler = func(command flags.Commander, args []string) error {
+		if err := initializeConfig(opts); err != nil {
+			return &mirror.ConfigError{Err: err}
+		}
+
+		// A bare invocation is kept as an alias of the mirror command.
+		if command == nil {
+			command = &opts.Mirror
 		}
-	}
 
-	if len(topics) == 0 {
-		return fmt.Errorf("no topics specified")
+		return command.Execute(args)
 	}
 
-	topicNames := make([]string, 0, len(topics))
-	topicOptions := make(map[string]TopicOption, len(topics))
-	for _, topic := range topics {
-		name, opt, err := toTopic(topic)
-		if err != nil {
-			return fmt.Errorf("failed to parse topic %q: %w", topic, err)
+	if _, err := parser.Parse(); err != nil {
+		if flags.WroteHelp(err) {
+			return nil
 		}
 
-		topicNames = append(topicNames, name)
-		topicOptions[name] = opt
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) {
+			return &mirror.ConfigError{Err: err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func initializeConfig(opts Options) error {
+	logger, err := newLogger(os.Stderr, opts.Log)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	if opts.Log.RecordSampling < 0 || opts.Log.RecordSampling > 1 {
+		return fmt.Errorf("log record sampling must be between 0 and 1, got %v", opts.Log.RecordSampling)
 	}
 
 	kVersion := kversion.FromString(opts.KafkaVersion)
@@ -60,18 +80,166 @@ func initializeConfig() error {
 		return fmt.Errorf("failed to parse sink options: %w", err)
 	}
 
+	producerOpts, err := toProducerOptions(opts.Producer)
+	if err != nil {
+		return fmt.Errorf("failed to parse sink producer options: %w", err)
+	}
+
+	sourceLogger, err := clientLogger(logger, sideSource, opts.Log.ClientLevel)
+	if err != nil {
+		return err
+	}
+	fetchOpts := toFetchOptions(opts.Fetch)
+	sourceTuning := append(fetchOpts, sourceLogger)
+
+	if sourceOpts != nil {
+		sourceOpts = append(sourceOpts, sourceTuning...)
+	}
+
+	sinkLogger, err := clientLogger(logger, sideSink, opts.Log.ClientLevel)
+	if err != nil {
+		return err
+	}
+	sinkTuning := append(producerOpts, sinkLogger)
+
+	if sinkOpts != nil {
+		sinkOpts = append(sinkOpts, sinkTuning...)
+	}
+
 	config.Sink = sinkOpts
 	config.Source = sourceOpts
+	config.SourceTuning = sourceTuning
+	config.SourceTimeout = opts.Source.Timeout
+	config.SinkTuning = sinkTuning
+	config.SinkTimeout = opts.Sink.Timeout
+	config.FetchTuning = fetchOpts
+	config.ProducerTuning = producerOpts
 	config.ClientID = opts.ClientID
 	config.KafkaVersion = kVersion
-	config.Topics = topicOptions
-	config.TopicNames = topicNames
 	config.Timeout = max(opts.Sink.Timeout, opts.Source.Timeout)
+	config.LogRecordSampling = opts.Log.RecordSampling
+
+	return nil
+}
+
+func setTopics(args []string) error {
+	topics, err := mirror.ParseTopics(args)
+	if err != nil {
+		return err
+	}
 
+	config.Topics = topics
 	return nil
 }
 
+// readSources reads the source clusters of the --sources file, a JSON array
+// of SourceCluster.
+func readSources(path string) ([]mirror.Source, error) {
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("failed to read sources: %w", err)}
+	}
+	defer func() { _ = file.Close() }()
+
+	var clusters []SourceCluster
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&clusters); err != nil {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("failed to decode sources %s: %w", path, err)}
+	}
+
+	sources := make([]mirror.Source, 0, len(clusters))
+	for _, cluster := range clusters {
+		source, err := toSource(cluster)
+		if err != nil {
+			return nil, &mirror.ConfigError{Err: fmt.Errorf("source %q: %w", cluster.Name, err)}
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func toSource(cluster SourceCluster) (mirror.Source, error) {
+	clientOpts, err := toFranzOptions(BrokerOptions{
+		Brokers: cluster.Brokers,
+		TLS:     cluster.TLS,
+		Sasl:    cluster.Sasl,
+		Timeout: config.SourceTimeout,
+	})
+	if err != nil {
+		return mirror.Source{}, err
+	}
+	if clientOpts == nil {
+		return mirror.Source{}, fmt.Errorf("no brokers configured")
+	}
+
+	topics, err := mirror.ParseTopics(cluster.Topics)
+	if err != nil {
+		return mirror.Source{}, err
+	}
+
+	source := mirror.Source{
+		Name:   cluster.Name,
+		Client: append(clientOpts, config.SourceTuning...),
+		Topics: topics,
+	}
+	for _, value := range cluster.Groups {
+		group, err := mirror.ParseGroup(value)
+		if err != nil {
+			return mirror.Source{}, err
+		}
+		source.Groups = append(source.Groups, group)
+	}
+	return source, nil
+}
+
+// readSinks reads the sink clusters of the --sinks file, a JSON array of
+// SinkCluster.
+func readSinks(path string) ([]mirror.Sink, error) {
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("failed to read sinks: %w", err)}
+	}
+	defer func() { _ = file.Close() }()
+
+	var clusters []SinkCluster
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&clusters); err != nil {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("failed to decode sinks %s: %w", path, err)}
+	}
+
+	sinks := make([]mirror.Sink, 0, len(clusters))
+	for _, cluster := range clusters {
+		clientOpts, err := toFranzOptions(BrokerOptions{
+			Brokers: cluster.Brokers,
+			TLS:     cluster.TLS,
+			Sasl:    cluster.Sasl,
+			Timeout: config.SinkTimeout,
+		})
+		if err == nil && clientOpts == nil {
+			err = fmt.Errorf("no brokers configured")
+		}
+		if err != nil {
+			return nil, &mirror.ConfigError{Err: fmt.Errorf("sink %q: %w", cluster.Name, err)}
+		}
+
+		sinks = append(sinks, mirror.Sink{
+			Name:   cluster.Name,
+			Client: append(clientOpts, config.SinkTuning...),
+			Topics: cluster.Topics,
+		})
+	}
+	return sinks, nil
+}
+
 func toFranzOptions(brokerOpts BrokerOptions) ([]kgo.Opt, error) {
+	// Not every command talks to both clusters, so a side without brokers
+	// is left unconfigured and rejected only when a client is requested.
+	if len(brokerOpts.Brokers) == 0 {
+		return nil, nil
+	}
+
 	out := make([]kgo.Opt, 0, 4)
 
 	out = append(out, kgo.SeedBrokers(brokerOpts.Brokers...))
@@ -126,54 +294,73 @@ func toFranzOptions(brokerOpts BrokerOptions) ([]kgo.Opt, error) {
 	return out, nil
 }
 
-func toTopic(value string) (name string, opt TopicOption, err error) {
-	parts := strings.Split(value, "@")
-	name = parts[0]
+// toProducerOptions returns the client options tuning the sink producer.
+func toProducerOptions(producerOpts ProducerOptions) ([]kgo.Opt, error) {
+	var codec kgo.CompressionCodec
+	switch producerOpts.Compression {
+	case "none":
+		codec = kgo.NoCompression()
+	case "gzip":
+		codec = kgo.GzipCompression()
+	case "snappy":
+		codec = kgo.SnappyCompression()
+	case "lz4":
+		codec = kgo.Lz4Compression()
+	case "zstd":
+		codec = kgo.ZstdCompression()
+	default:
+		return nil, fmt.Errorf("unknown compression codec %q", producerOpts.Compression)
+	}
 
-	switch len(parts) {
-	case 1:
-		opt = TopicOption{Offset: -1}
-	case 2:
-		opt, err = parseTopicOffset(parts[1])
+	var acks kgo.Acks
+	switch producerOpts.Acks {
+	case "all":
+		acks = kgo.AllISRAcks()
+	case "leader":
+		acks = kgo.LeaderAck()
+	case "none":
+		acks = kgo.NoAck()
 	default:
-		err = fmt.Errorf("expected topic@offset or topic@partition:offset,partition:offset... got %q", value)
+		return nil, fmt.Errorf("unknown acks %q", producerOpts.Acks)
 	}
 
-	return
-}
+	if producerOpts.Acks != "all" && !producerOpts.DisableIdempotence {
+		return nil, fmt.Errorf("acks %q requires idempotence to be disabled", producerOpts.Acks)
+	}
 
-func parseTopicOffset(value string) (TopicOption, error) {
-	offsetsOrOffsetPerPartition := strings.Split(value, ",")
-	if len(offsetsOrOffsetPerPartition) == 1 {
-		offset, err := strconv.ParseInt(offsetsOrOffsetPerPartition[0], 10, 64)
-		if err != nil {
-			return TopicOption{}, fmt.Errorf("failed to parse offset %q: %w", offsetsOrOffsetPerPartition[0], err)
-		}
+	if producerOpts.MaxBatchBytes <= 0 {
+		return nil, fmt.Errorf("max batch bytes must be positive, got %d", producerOpts.MaxBatchBytes)
+	}
 
-		return TopicOption{Offset: offset}, nil
+	if producerOpts.MaxBufferedRecords <= 0 {
+		return nil, fmt.Errorf("max buffered records must be positive, got %d", producerOpts.MaxBufferedRecords)
 	}
 
-	out := TopicOption{
-		PerPartitionOffset: map[int32]int64{},
+	out := []kgo.Opt{
+		kgo.ProducerBatchCompression(codec),
+		kgo.RequiredAcks(acks),
+		kgo.ProducerLinger(producerOpts.Linger),
+		kgo.ProducerBatchMaxBytes(producerOpts.MaxBatchBytes),
+		kgo.MaxBufferedRecords(producerOpts.MaxBufferedRecords),
 	}
-	for _, offsets := range offsetsOrOffsetPerPartition {
-		partitionOffset := strings.Split(offsets, ":")
-		if len(partitionOffset) != 2 {
-			return out, fmt.Errorf("expected partition:offset, got %q", offsets)
-		}
 
-		partition, err := strconv.ParseInt(partitionOffset[0], 10, 32)
-		if err != nil {
-			return out, fmt.Errorf("failed to parse partition %q: %w", partitionOffset[0], err)
-		}
+	if producerOpts.DisableIdempotence {
+		out = append(out, kgo.DisableIdempotentWrite())
+	}
 
-		offset, err := strconv.ParseInt(partitionOffset[1], 10, 64)
-		if err != nil {
-			return out, fmt.Errorf("failed to parse offset %q: %w", partitionOffset[1], err)
-		}
+	return out, nil
+}
 
-		out.PerPartitionOffset[int32(partition)] = offset
+// toFetchOptions returns the client options tuning the source consumer.
+func toFetchOptions(fetchOpts FetchOptions) []kgo.Opt {
+	isolation := kgo.ReadCommitted()
+	if fetchOpts.Isolation == "read_uncommitted" {
+		isolation = kgo.ReadUncommitted()
 	}
 
-	return out, nil
+	return []kgo.Opt{
+		kgo.FetchMaxBytes(fetchOpts.MaxBytes),
+		kgo.FetchMaxWait(fetchOpts.MaxWait),
+		kgo.FetchIsolationLevel(isolation),
+	}
 }
@@ -1,270 +1,183 @@
 package main
 
 import (
-	"strings"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-func TestParseTopicOffset(t *testing.T) {
+func TestToProducerOptions(t *testing.T) {
+	defaults := ProducerOptions{
+		Compression:        "snappy",
+		Acks:               "all",
+		Linger:             10 * time.Millisecond,
+		MaxBatchBytes:      1000012,
+		MaxBufferedRecords: 10000,
+	}
+
 	tests := []struct {
 		name    string
-		value   string
-		want    TopicOption
+		modify  func(*ProducerOptions)
 		wantErr bool
 	}{
-		{
-			name:    "single offset - positive",
-			value:   "100",
-			want:    TopicOption{Offset: 100},
-			wantErr: false,
-		},
-		{
-			name:    "single offset - zero",
-			value:   "0",
-			want:    TopicOption{Offset: 0},
-			wantErr: false,
-		},
-		{
-			name:    "single offset - negative",
-			value:   "-1",
-			want:    TopicOption{Offset: -1},
-			wantErr: false,
-		},
-		{
-			name:    "single partition offset (comma required for multiple)",
-			value:   "0:100",
-			want:    TopicOption{},
-			wantErr: true,
-		},
-		{
-			name:  "multiple partition offsets",
-			value: "0:100,1:200,2:300",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{0: 100, 1: 200, 2: 300},
-			},
-			wantErr: false,
-		},
-		{
-			name:    "invalid offset - non-numeric",
-			value:   "abc",
-			want:    TopicOption{},
-			wantErr: true,
-		},
-		{
-			name:  "invalid partition offset - missing colon",
-			value: "0-100",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{},
-			},
-			wantErr: true,
-		},
-		{
-			name:  "invalid partition offset - invalid partition",
-			value: "abc:100",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{},
-			},
-			wantErr: true,
-		},
-		{
-			name:  "invalid partition offset - invalid offset",
-			value: "0:abc",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{},
-			},
-			wantErr: true,
-		},
-		{
-			name:  "invalid partition offset - three parts",
-			value: "0:100:200",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{},
-			},
-			wantErr: true,
-		},
-		{
-			name:  "partition offsets with negative values",
-			value: "0:-1,1:0",
-			want: TopicOption{
-				PerPartitionOffset: map[int32]int64{0: -1, 1: 0},
-			},
-			wantErr: false,
-		},
+		{name: "defaults", modify: func(*ProducerOptions) {}},
+		{name: "zstd", modify: func(o *ProducerOptions) { o.Compression = "zstd" }},
+		{name: "unknown codec", modify: func(o *ProducerOptions) { o.Compression = "brotli" }, wantErr: true},
+		{name: "leader ack without idempotence", modify: func(o *ProducerOptions) {
+			o.Acks = "leader"
+			o.DisableIdempotence = true
+		}},
+		{name: "no ack with idempotence", modify: func(o *ProducerOptions) { o.Acks = "none" }, wantErr: true},
+		{name: "unknown acks", modify: func(o *ProducerOptions) { o.Acks = "2" }, wantErr: true},
+		{name: "zero batch bytes", modify: func(o *ProducerOptions) { o.MaxBatchBytes = 0 }, wantErr: true},
+		{name: "zero buffered records", modify: func(o *ProducerOptions) { o.MaxBufferedRecords = 0 }, wantErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseTopicOffset(tt.value)
+			opts := defaults
+			tt.modify(&opts)
+
+			got, err := toProducerOptions(opts)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseTopicOffset() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("toProducerOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
 				return
 			}
-			if !tt.wantErr {
-				if got.Offset != tt.want.Offset {
-					t.Errorf("parseTopicOffset() Offset = %v, want %v", got.Offset, tt.want.Offset)
-				}
-				if len(got.PerPartitionOffset) != len(tt.want.PerPartitionOffset) {
-					t.Errorf("parseTopicOffset() PerPartitionOffset length = %v, want %v", len(got.PerPartitionOffset), len(tt.want.PerPartitionOffset))
-				}
-				for k, v := range tt.want.PerPartitionOffset {
-					if got.PerPartitionOffset[k] != v {
-						t.Errorf("parseTopicOffset() PerPartitionOffset[%d] = %v, want %v", k, got.PerPartitionOffset[k], v)
-					}
-				}
+
+			// The client validates its options without connecting.
+			client, err := kgo.NewClient(append(got, kgo.SeedBrokers("localhost:9092"))...)
+			if err != nil {
+				t.Fatalf("kgo.NewClient() error = %v", err)
 			}
+			client.Close()
 		})
 	}
 }
 
-func TestToTopic(t *testing.T) {
+func TestToFetchOptions(t *testing.T) {
+	opts := toFetchOptions(FetchOptions{MaxBytes: 1 << 20, MaxWait: time.Second})
+
+	client, err := kgo.NewClient(append(opts, kgo.SeedBrokers("localhost:9092"))...)
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	if got := client.OptValue(kgo.FetchMaxBytes); got != int32(1<<20) {
+		t.Errorf("FetchMaxBytes = %v, want %d", got, 1<<20)
+	}
+	if got := client.OptValue(kgo.FetchMaxWait); got != time.Second {
+		t.Errorf("FetchMaxWait = %v, want %v", got, time.Second)
+	}
+	if got := client.OptValue(kgo.FetchIsolationLevel); got != int8(1) {
+		t.Errorf("FetchIsolationLevel = %v, want read committed", got)
+	}
+}
+
+func TestToFetchOptions_ReadUncommitted(t *testing.T) {
+	opts := toFetchOptions(FetchOptions{MaxBytes: 1 << 20, MaxWait: time.Second, Isolation: "read_uncommitted"})
+
+	client, err := kgo.NewClient(append(opts, kgo.SeedBrokers("localhost:9092"))...)
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	if got := client.OptValue(kgo.FetchIsolationLevel); got != int8(0) {
+		t.Errorf("FetchIsolationLevel = %v, want read uncommitted", got)
+	}
+}
+
+func TestReadSources(t *testing.T) {
 	tests := []struct {
 		name    string
-		value   string
-		want    string
-		wantOpt TopicOption
+		content string
+		want    []string
 		wantErr bool
 	}{
 		{
-			name:    "topic only",
-			value:   "my-topic",
-			want:    "my-topic",
-			wantOpt: TopicOption{Offset: -1},
-			wantErr: false,
-		},
-		{
-			name:    "topic with offset",
-			value:   "my-topic@100",
-			want:    "my-topic",
-			wantOpt: TopicOption{Offset: 100},
-			wantErr: false,
-		},
-		{
-			name:    "topic with partition offsets",
-			value:   "my-topic@0:100,1:200",
-			want:    "my-topic",
-			wantOpt: TopicOption{PerPartitionOffset: map[int32]int64{0: 100, 1: 200}},
-			wantErr: false,
-		},
-		{
-			name:    "topic with dash",
-			value:   "my-topic-123",
-			want:    "my-topic-123",
-			wantOpt: TopicOption{Offset: -1},
-			wantErr: false,
-		},
-		{
-			name:    "topic with offset zero",
-			value:   "my-topic@0",
-			want:    "my-topic",
-			wantOpt: TopicOption{Offset: 0},
-			wantErr: false,
-		},
-		{
-			name:    "invalid - multiple at signs",
-			value:   "my-topic@100@200",
-			want:    "my-topic",
-			wantOpt: TopicOption{},
-			wantErr: true,
-		},
-		{
-			name:    "empty topic name with offset (valid by parsing logic)",
-			value:   "@100",
-			want:    "",
-			wantOpt: TopicOption{Offset: 100},
-			wantErr: false,
-		},
-		{
-			name:    "invalid - bad offset format",
-			value:   "my-topic@abc",
-			want:    "my-topic",
-			wantOpt: TopicOption{},
-			wantErr: true,
-		},
-		{
-			name:    "topic with underscores",
-			value:   "my_topic_123",
-			want:    "my_topic_123",
-			wantOpt: TopicOption{Offset: -1},
-			wantErr: false,
-		},
-		{
-			name:    "topic with dots",
-			value:   "my.topic.name",
-			want:    "my.topic.name",
-			wantOpt: TopicOption{Offset: -1},
-			wantErr: false,
-		},
+			name: "valid",
+			content: `[
+				{"name": "eu", "brokers": ["eu:9092"], "topics": ["orders@-2"], "groups": ["orders-service"]},
+				{"name": "us", "brokers": ["us:9092"], "sasl": {"enabled": true, "mechanism": "plain", "username": "u", "password": "p"}, "topics": ["orders"]}
+			]`,
+			want: []string{"eu", "us"},
+		},
+		{name: "not json", content: `eu:9092`, wantErr: true},
+		{name: "unknown field", content: `[{"name": "eu", "brokers": ["eu:9092"], "topics": ["orders"], "partitions": 3}]`, wantErr: true},
+		{name: "no brokers", content: `[{"name": "eu", "topics": ["orders"]}]`, wantErr: true},
+		{name: "no topics", content: `[{"name": "eu", "brokers": ["eu:9092"]}]`, wantErr: true},
+		{name: "invalid topic", content: `[{"name": "eu", "brokers": ["eu:9092"], "topics": ["orders@x"]}]`, wantErr: true},
+		{name: "incomplete sasl", content: `[{"name": "eu", "brokers": ["eu:9092"], "sasl": {"enabled": true}, "topics": ["orders"]}]`, wantErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, gotOpt, err := toTopic(tt.value)
+			path := filepath.Join(t.TempDir(), "sources.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("os.WriteFile() error = %v", err)
+			}
+
+			got, err := readSources(path)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("toTopic() error = %v, wantErr %v", err, tt.wantErr)
-				return
+				t.Fatalf("readSources() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got != tt.want {
-				t.Errorf("toTopic() got = %v, want %v", got, tt.want)
+
+			var names []string
+			for _, source := range got {
+				names = append(names, source.Name)
 			}
-			if !tt.wantErr {
-				if gotOpt.Offset != tt.wantOpt.Offset {
-					t.Errorf("toTopic() Offset = %v, want %v", gotOpt.Offset, tt.wantOpt.Offset)
-				}
-				if len(gotOpt.PerPartitionOffset) != len(tt.wantOpt.PerPartitionOffset) {
-					t.Errorf("toTopic() PerPartitionOffset length = %v, want %v", len(gotOpt.PerPartitionOffset), len(tt.wantOpt.PerPartitionOffset))
-				}
-				for k, v := range tt.wantOpt.PerPartitionOffset {
-					if gotOpt.PerPartitionOffset[k] != v {
-						t.Errorf("toTopic() PerPartitionOffset[%d] = %v, want %v", k, gotOpt.PerPartitionOffset[k], v)
-					}
-				}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("readSources() sources = %v, want %v", names, tt.want)
 			}
 		})
 	}
 }
 
-func TestToTopic_LongTopicNames(t *testing.T) {
-	longTopic := strings.Repeat("a", 249) + "@100"
-	got, gotOpt, err := toTopic(longTopic)
-
-	if err != nil {
-		t.Errorf("toTopic() unexpected error: %v", err)
-	}
-	if len(got) != 249 {
-		t.Errorf("toTopic() got topic length = %v, want 249", len(got))
-	}
-	if gotOpt.Offset != 100 {
-		t.Errorf("toTopic() Offset = %v, want 100", gotOpt.Offset)
-	}
-}
-
-func BenchmarkParseTopicOffset(b *testing.B) {
-	tests := []string{
-		"100",
-		"0:100,1:200,2:300",
-		"0:100,1:200,2:300,3:400,4:500,5:600,6:700,7:800",
+func TestReadSinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			content: `[
+				{"name": "alice", "brokers": ["alice:9092"]},
+				{"name": "bob", "brokers": ["bob:9092"], "tls": {"enabled": true, "insecure": true}, "topics": ["users"]}
+			]`,
+			want: []string{"alice", "bob"},
+		},
+		{name: "unknown field", content: `[{"name": "alice", "brokers": ["alice:9092"], "group": "x"}]`, wantErr: true},
+		{name: "no brokers", content: `[{"name": "alice"}]`, wantErr: true},
+		{name: "unknown sasl mechanism", content: `[{"name": "alice", "brokers": ["alice:9092"], "sasl": {"enabled": true, "mechanism": "md5", "username": "u", "password": "p"}}]`, wantErr: true},
 	}
 
 	for _, tt := range tests {
-		b.Run(tt, func(b *testing.B) {
-			for i := 0; i < b.N; i++ {
-				_, _ = parseTopicOffset(tt)
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sinks.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("os.WriteFile() error = %v", err)
 			}
-		})
-	}
-}
 
-func BenchmarkToTopic(b *testing.B) {
-	tests := []string{
-		"my-topic",
-		"my-topic@100",
-		"my-topic@0:100,1:200,2:300",
-	}
+			got, err := readSinks(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
 
-	for _, tt := range tests {
-		b.Run(tt, func(b *testing.B) {
-			for i := 0; i < b.N; i++ {
-				_, _, _ = toTopic(tt)
+			var names []string
+			for _, sink := range got {
+				names = append(names, sink.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("readSinks() sinks = %v, want %v", names, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// confirmDeletionPrompt returns the function asking for the deletion of sink
+// topics on the terminal, refusing it without one.
+func confirmDeletionPrompt(in io.Reader, out io.Writer) func(sink string, topics []string) (bool, error) {
+	return func(sink string, topics []string) (bool, error) {
+		if !isTerminal(in) {
+			return false, fmt.Errorf("refusing to delete sink topics %v without confirmation, use --yes", topics)
+		}
+		return confirmDeletion(in, out, sink, topics)
+	}
+}
+
+// confirmDeletion asks the user to confirm the deletion of the given sink
+// topics and reports whether they agreed.
+func confirmDeletion(in io.Reader, out io.Writer, sink string, topics []string) (bool, error) {
+	_, _ = fmt.Fprintf(out, "The following topics will be deleted on sink cluster %s:\n", sink)
+	for _, topic := range topics {
+		_, _ = fmt.Fprintf(out, "  - %s\n", topic)
+	}
+	_, _ = fmt.Fprint(out, "Continue? [y/N] ")
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func isTerminal(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfirmDeletion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.input), func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := confirmDeletion(strings.NewReader(tt.input), &out, "local", []string{"orders", "users"})
+			if err != nil {
+				t.Fatalf("confirmDeletion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("confirmDeletion() = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(out.String(), "  - orders\n  - users\n") {
+				t.Errorf("confirmDeletion() did not list topics:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+// Exit codes returned by kmir. They are part of the CLI contract, see the
+// README, so existing values must never change.
+const (
+	ExitOK           = 0
+	ExitFailure      = 1
+	ExitConfig       = 2
+	ExitAuth         = 3
+	ExitMissingTopic = 4
+	ExitSinkWrite    = 5
+	ExitTimeout      = 6
+)
+
+// exitCode maps err to the exit code kmir terminates with. Authentication
+// and authorization failures win over the error they are wrapped in, since
+// they are the root cause whatever operation hit them.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	if isAuthError(err) {
+		return ExitAuth
+	}
+
+	var (
+		configErr    *mirror.ConfigError
+		missingErr   *mirror.MissingTopicError
+		sinkWriteErr *mirror.SinkWriteError
+		timeoutErr   *mirror.TimeoutError
+	)
+	switch {
+	case errors.As(err, &configErr):
+		return ExitConfig
+	case errors.As(err, &missingErr):
+		return ExitMissingTopic
+	case errors.As(err, &sinkWriteErr):
+		return ExitSinkWrite
+	case errors.As(err, &timeoutErr):
+		return ExitTimeout
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ExitTimeout
+	}
+
+	return ExitFailure
+}
+
+func isAuthError(err error) bool {
+	for _, authFailure := range []error{
+		kerr.SaslAuthenticationFailed,
+		kerr.UnsupportedSaslMechanism,
+		kerr.IllegalSaslState,
+		kerr.TopicAuthorizationFailed,
+		kerr.GroupAuthorizationFailed,
+		kerr.ClusterAuthorizationFailed,
+		kerr.TransactionalIDAuthorizationFailed,
+		kerr.DelegationTokenAuthorizationFailed,
+	} {
+		if errors.Is(err, authFailure) {
+			return true
+		}
+	}
+
+	var (
+		certErr     *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		certInvalid x509.CertificateInvalidError
+	)
+	return errors.As(err, &certErr) || errors.As(err, &unknownAuth) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &certInvalid)
+}
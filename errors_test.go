@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mortezaPRK/kmir/mirror"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"generic", errors.New("boom"), ExitFailure},
+		{"config", &mirror.ConfigError{Err: errors.New("bad flag")}, ExitConfig},
+		{"wrapped config", fmt.Errorf("init: %w", &mirror.ConfigError{Err: errors.New("bad flag")}), ExitConfig},
+		{"sasl failure", fmt.Errorf("list topics: %w", kerr.SaslAuthenticationFailed), ExitAuth},
+		{"auth inside sink write", &mirror.SinkWriteError{Err: kerr.TopicAuthorizationFailed}, ExitAuth},
+		{"missing topic", &mirror.MissingTopicError{Topics: []string{"orders"}}, ExitMissingTopic},
+		{"sink write", &mirror.SinkWriteError{Err: errors.New("produce failed")}, ExitSinkWrite},
+		{"timeout", &mirror.TimeoutError{Err: errors.New("waited too long")}, ExitTimeout},
+		{"deadline exceeded", fmt.Errorf("list topics: %w", context.DeadlineExceeded), ExitTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/plugin/kslog"
+)
+
+// Cluster sides, used as the value of the "side" log attribute.
+const (
+	sideSource = "source"
+	sideSink   = "sink"
+)
+
+func newLogger(w io.Writer, opts LogOptions) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch opts.Format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	case "text", "":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+}
+
+// clientLogger returns the option that routes the logs of a franz-go client
+// through logger, tagged with its side and never below the given level.
+func clientLogger(logger *slog.Logger, side, minLevel string) (kgo.Opt, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(minLevel)); err != nil {
+		return nil, fmt.Errorf("invalid client log level %q: %w", minLevel, err)
+	}
+
+	handler := minLevelHandler{Handler: logger.Handler(), level: level}
+	return kgo.WithLogger(kslog.New(slog.New(handler).With(slog.String("side", side)))), nil
+}
+
+// minLevelHandler drops records below level, on top of the level of the
+// wrapped handler.
+type minLevelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h minLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+func (h minLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return minLevelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h minLevelHandler) WithGroup(name string) slog.Handler {
+	return minLevelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
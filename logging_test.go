@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    LogOptions
+		wantErr bool
+	}{
+		{name: "text", opts: LogOptions{Level: "info", Format: "text"}},
+		{name: "json", opts: LogOptions{Level: "debug", Format: "json"}},
+		{name: "invalid level", opts: LogOptions{Level: "loud", Format: "text"}, wantErr: true},
+		{name: "invalid format", opts: LogOptions{Level: "info", Format: "xml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLogger(&bytes.Buffer{}, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewLogger_JSONLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, LogOptions{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown", slog.String("side", sideSink))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d lines, want 1:\n%s", len(lines), buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry["msg"] != "shown" || entry["side"] != sideSink {
+		t.Errorf("log entry = %v", entry)
+	}
+}
+
+func TestMinLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(minLevelHandler{Handler: base, level: slog.LevelWarn}).With(slog.String("side", sideSource))
+
+	logger.Info("dropped")
+	logger.Error("kept")
+
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("minLevelHandler output = %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "side=source") {
+		t.Errorf("minLevelHandler lost attributes: %q", buf.String())
+	}
+}
@@ -4,245 +4,204 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"math"
-	"time"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
 
+	"github.com/mortezaPRK/kmir/mirror"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
 func main() {
-	if err := initializeConfig(); err != nil {
-		slog.Error("Failed to initialize config", slog.Any("error", err))
-		return
+	if err := run(); err != nil {
+		slog.Error("Failed to run", slog.Any("error", err))
+		os.Exit(exitCode(err))
 	}
+}
 
-	var rootCtx = context.Background()
-
-	slog.Info("Creating source Kafka client")
-	sourceClient, sourceAdminClient, err := getClients(config.Source)
-	if err != nil {
-		slog.Error("Failed to create source Kafka client", slog.Any("error", err))
-		return
-	}
-	defer sourceClient.Close()
-
-	slog.Info("Creating sink Kafka client")
-	sinkClient, sinkAdminClient, err := getClients(config.Sink)
-	if err != nil {
-		slog.Error("Failed to create sink Kafka client", slog.Any("error", err))
-		return
+// Execute runs the mirror command.
+func (c *MirrorCommand) Execute(args []string) error {
+	if c.Sources != "" {
+		if len(args) > 0 {
+			return &mirror.ConfigError{Err: fmt.Errorf("topics are listed per source in --sources, got %v", args)}
+		}
+	} else if err := setTopics(args); err != nil {
+		return err
 	}
-	defer sinkClient.Close()
 
-	slog.Info("Getting source topics")
-	sourceTopics, err := getTopics(rootCtx, sourceAdminClient)
-	if err != nil {
-		slog.Error("Failed to get source topics", slog.Any("error", err))
-		return
-	}
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	slog.Info("Getted sink topics")
-	sinkTopics, err := getTopics(rootCtx, sinkAdminClient)
+	opts, err := c.options()
 	if err != nil {
-		slog.Error("Failed to get sink topics", slog.Any("error", err))
-		return
-	}
-
-	slog.Info("Checking source topics")
-	if err := checkTopics(sourceTopics); err != nil {
-		slog.Error("Failed to check source topics", slog.Any("error", err))
-		return
+		return err
 	}
 
-	slog.Info("Deleting existing sink topics")
-	if err := deleteExistingTopics(rootCtx, sinkAdminClient, sinkTopics); err != nil {
-		slog.Error("Failed to delete existing sink topics", slog.Any("error", err))
-		return
+	if c.Sinks != "" {
+		if opts.Sinks, err = readSinks(c.Sinks); err != nil {
+			return err
+		}
 	}
 
-	slog.Info("Creating sink topics")
-	if err := createTopics(rootCtx, sinkAdminClient, sourceTopics); err != nil {
-		slog.Error("Failed to create sink topics", slog.Any("error", err))
-		return
+	if len(c.ReverseTopics) > 0 {
+		d, err := c.bidirectional(opts)
+		if err != nil {
+			return err
+		}
+		return d.Run(rootCtx)
 	}
 
-	slog.Info("Configuring consumer")
-	configureConsumer(sourceClient, sourceTopics)
-
-	slog.Info("Starting mirror")
-	for {
-		fetches := sourceClient.PollFetches(rootCtx)
-		fetches.EachError(func(s string, i int32, err error) {
-			slog.LogAttrs(
-				rootCtx,
-				slog.LevelError,
-				"error fetching topic",
-				slog.String("topic", s),
-				slog.Int("partition", int(i)),
-				slog.Any("error", err),
-			)
-		})
-
-		if fetches.Err() != nil {
-			return
+	if c.Sources != "" {
+		sources, err := readSources(c.Sources)
+		if err != nil {
+			return err
 		}
 
-		slog.Info("Processing fetches")
-		fetches.EachRecord(func(r *kgo.Record) {
-			sinkClient.Produce(rootCtx, r, nil)
-		})
-	}
-}
-
-func wait(timeout time.Duration, fn func() bool) error {
-	start := time.Now()
-	for !fn() {
-		if time.Since(start) > timeout {
-			return fmt.Errorf("timeout waiting for condition")
+		f, err := mirror.NewFanIn(opts, sources, c.TopicCollisions)
+		if err != nil {
+			return err
 		}
-		time.Sleep(time.Second)
+		return f.Run(rootCtx)
 	}
-	return nil
-}
-
-func getTopics(rootCtx context.Context, client *kadm.Client) (kadm.TopicDetails, error) {
-	ctx, cancel := context.WithTimeout(rootCtx, config.Timeout)
-	defer cancel()
 
-	sourceTopics, err := client.ListTopics(ctx, config.TopicNames...)
+	m, err := mirror.New(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list source topics: %w", err)
+		return err
 	}
-
-	return sourceTopics, nil
+	return m.Run(rootCtx)
 }
 
-func checkTopics(sourceTopics kadm.TopicDetails) error {
-	missingTopics := make([]string, 0)
-
-	for topic := range config.Topics {
-		if !sourceTopics.Has(topic) {
-			missingTopics = append(missingTopics, topic)
+// options returns the options of the mirror run by the command.
+func (c *MirrorCommand) options() (mirror.Options, error) {
+	opts := mirror.Options{
+		Source:              config.Source,
+		Sink:                config.Sink,
+		Topics:              config.Topics,
+		Timeout:             config.Timeout,
+		DryRun:              c.DryRun,
+		PlanFormat:          c.Format,
+		Output:              os.Stdout,
+		DeleteExisting:      c.Yes,
+		ConfirmDeletion:     confirmDeletionPrompt(os.Stdin, os.Stderr),
+		AllowSink:           c.AllowSink,
+		MetricsAddr:         c.MetricsAddr,
+		ControlAddr:         c.ControlAddr,
+		PauseSignals:        true,
+		PartitionQueue:      c.PartitionQueue,
+		PreserveCompression: c.PreserveCompression,
+		Sample:              c.Sample,
+		Throttle:            mirror.ThrottleOptions(c.Throttle),
+		Latest:              mirror.LatestOptions(c.Latest),
+		ExactlyOnce:         mirror.ExactlyOnceOptions(c.ExactlyOnce),
+		MinInsyncReplicas:   c.SinkMinInsyncReplicas,
+		SinkPartitions:      c.SinkPartitions,
+		PartitionRouting:    c.SinkPartitionRouting,
+		GroupSyncInterval:   c.GroupSyncInterval,
+		ACLs:                c.ACLs,
+		OffsetMapFile:       c.OffsetMap,
+		OffsetMapInterval:   c.OffsetMapInterval,
+		LogRecordSampling:   config.LogRecordSampling,
+	}
+
+	switch c.SinkReplicationFactor {
+	case "":
+	case "source":
+		opts.SourceReplicationFactor = true
+	default:
+		replicationFactor, err := strconv.ParseInt(c.SinkReplicationFactor, 10, 16)
+		if err != nil || replicationFactor <= 0 {
+			return opts, &mirror.ConfigError{Err: fmt.Errorf("expected a positive number or source as sink replication factor, got %q", c.SinkReplicationFactor)}
 		}
+		opts.ReplicationFactor = int16(replicationFactor)
 	}
 
-	if len(missingTopics) > 0 {
-		return fmt.Errorf("source topics missing: %v", missingTopics)
-	}
-	return nil
-}
-
-func deleteExistingTopics(rootCtx context.Context, client *kadm.Client, sinkTopics kadm.TopicDetails) error {
-	topicsToDelete := make([]string, 0)
-
-	for topic := range config.Topics {
-		if sinkTopics.Has(topic) {
-			topicsToDelete = append(topicsToDelete, topic)
+	for _, value := range c.Groups {
+		group, err := mirror.ParseGroup(value)
+		if err != nil {
+			return opts, &mirror.ConfigError{Err: err}
 		}
+		opts.Groups = append(opts.Groups, group)
 	}
 
-	if len(topicsToDelete) == 0 {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(rootCtx, config.Timeout)
-	defer cancel()
-
-	if _, err := client.DeleteTopics(ctx, topicsToDelete...); err != nil {
-		return fmt.Errorf("failed to delete topic %v: %w", topicsToDelete, err)
-	}
-
-	if err := wait(config.Timeout, func() bool {
-		ctx, cancel = context.WithTimeout(rootCtx, config.Timeout)
-		defer cancel()
-
-		sinkTopics, err := client.ListTopics(ctx, topicsToDelete...)
+	for _, value := range c.PrincipalRewrites {
+		rewrite, err := mirror.ParsePrincipalRewrite(value)
 		if err != nil {
-			slog.Error("Failed to list sink topics to check if they are deleted", slog.Any("error", err))
-			return false
+			return opts, &mirror.ConfigError{Err: err}
 		}
+		opts.PrincipalRewrites = append(opts.PrincipalRewrites, rewrite)
+	}
 
-		for _, topic := range topicsToDelete {
-			if sinkTopics.Has(topic) {
-				return false
-			}
+	if c.Progress {
+		tty := isTerminal(os.Stdout)
+		opts.Progress = progressReporter(os.Stdout, tty)
+		opts.ProgressInterval = progressLogInterval
+		if tty {
+			opts.ProgressInterval = progressInterval
 		}
-
-		return true
-	}); err != nil {
-		return fmt.Errorf("wait for topics deletion: %w", err)
 	}
 
-	return nil
+	return opts, nil
 }
 
-func createTopics(rootCtx context.Context, client *kadm.Client, sourceTopics kadm.TopicDetails) error {
-	for _, topic := range config.TopicNames {
-		if err := createTopic(rootCtx, client, sourceTopics[topic], topic); err != nil {
-			return fmt.Errorf("createTopic %q: %w", topic, err)
-		}
+// bidirectional returns the mirror of the topic arguments from the source to
+// the sink, and of --reverse-topic from the sink to the source. Both clusters
+// are consumed and produced to, so both get the fetch and producer options.
+func (c *MirrorCommand) bidirectional(opts mirror.Options) (*mirror.Bidirectional, error) {
+	if c.Sources != "" || c.Sinks != "" {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("--reverse-topic isn't supported with --sources or --sinks")}
+	}
+	if config.Source == nil || config.Sink == nil {
+		return nil, &mirror.ConfigError{Err: fmt.Errorf("no brokers configured")}
 	}
 
-	if err := wait(config.Timeout, func() bool {
-		ctx, cancel := context.WithTimeout(rootCtx, config.Timeout)
-		defer cancel()
-
-		sinkTopics, err := client.ListTopics(ctx, config.TopicNames...)
-		if err != nil {
-			slog.Error("Failed to list sink topics to check if they are created", slog.Any("error", err))
-			return false
-		}
-
-		for _, topic := range config.TopicNames {
-			if !sinkTopics.Has(topic) {
-				return false
-			}
-		}
-
-		return true
-	}); err != nil {
-		return fmt.Errorf("wait for topics creation: %w", err)
+	reverseTopics, err := mirror.ParseTopics(c.ReverseTopics)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	source := mirror.Cluster{
+		Name:   c.SourceName,
+		Client: append(slices.Clone(config.Source), config.ProducerTuning...),
+		Topics: opts.Topics,
+	}
+	sink := mirror.Cluster{
+		Name:   c.SinkName,
+		Client: append(slices.Clone(config.Sink), config.FetchTuning...),
+		Topics: reverseTopics,
+	}
+	return mirror.NewBidirectional(opts, source, sink)
 }
 
-func createTopic(rootCtx context.Context, client *kadm.Client, dt kadm.TopicDetail, topic string) error {
-	ctx, cancel := context.WithTimeout(rootCtx, config.Timeout)
+func (cfg *Config) getTopics(rootCtx context.Context, client *kadm.Client) (kadm.TopicDetails, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, cfg.Timeout)
 	defer cancel()
 
-	numPartitions := len(dt.Partitions)
-	if numPartitions > int(math.MaxInt32) {
-		return fmt.Errorf("number of partitions %d exceeds maximum int32 value", numPartitions)
+	sourceTopics, err := client.ListTopics(ctx, mirror.TopicNames(cfg.Topics)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source topics: %w", err)
 	}
-	partitions := int32(numPartitions) // #nosec G115
 
-	if _, err := client.CreateTopic(ctx, partitions, -1, nil, topic); err != nil {
-		return fmt.Errorf("failed to create topic %q: %w", topic, err)
-	}
-	return nil
+	return sourceTopics, nil
 }
 
-func configureConsumer(client *kgo.Client, sourceTopics kadm.TopicDetails) {
-	partitions := map[string]map[int32]kgo.Offset{}
-	for topic, dt := range sourceTopics {
-		cfg := config.Topics[topic]
-		offsetCfg := map[int32]kgo.Offset{}
-
-		for _, partition := range dt.Partitions {
-			if offset, ok := cfg.OffsetOf(partition.Partition); ok {
-				offsetCfg[partition.Partition] = kgo.NewOffset().At(offset)
-			}
-		}
+func (cfg *Config) getWatermarks(rootCtx context.Context, client *kadm.Client, committed bool, topics ...string) (map[string]map[int32]mirror.Watermark, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, cfg.Timeout)
+	defer cancel()
 
-		partitions[topic] = offsetCfg
+	if committed {
+		return mirror.ListCommittedWatermarks(ctx, client, topics...)
 	}
-
-	client.AddConsumePartitions(partitions)
+	return mirror.ListWatermarks(ctx, client, topics...)
 }
 
 func getClients(opts []kgo.Opt) (*kgo.Client, *kadm.Client, error) {
+	if opts == nil {
+		return nil, nil, fmt.Errorf("no brokers configured")
+	}
+
 	client, err := kgo.NewClient(opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create source admin client: %w", err)
@@ -0,0 +1,234 @@
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// PrincipalRewrite replaces the principal From of mirrored ACLs with To,
+// e.g. User:orders-service with a user of the local cluster.
+type PrincipalRewrite struct {
+	From string
+	To   string
+}
+
+// ParsePrincipalRewrite parses a principal rewrite argument, as from=to.
+func ParsePrincipalRewrite(value string) (PrincipalRewrite, error) {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" || to == "" {
+		return PrincipalRewrite{}, fmt.Errorf("expected from-principal=to-principal, got %q", value)
+	}
+	return PrincipalRewrite{From: from, To: to}, nil
+}
+
+// plannedACL is a source ACL that is going to be created on the sink.
+type plannedACL struct {
+	Principal  string
+	Host       string
+	Type       kmsg.ACLResourceType
+	Name       string
+	Pattern    kadm.ACLPattern
+	Operation  kadm.ACLOperation
+	Permission kmsg.ACLPermissionType
+}
+
+// MarshalJSON encodes the enums of the ACL by name, as they are listed by
+// the Kafka tools.
+func (a plannedACL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"principal":  a.Principal,
+		"host":       a.Host,
+		"type":       a.Type.String(),
+		"name":       a.Name,
+		"pattern":    a.Pattern.String(),
+		"operation":  a.Operation.String(),
+		"permission": a.Permission.String(),
+	})
+}
+
+// describeACLs returns the ACLs of the source applying to the mirrored topics
+// and consumer groups, literal, prefixed or wildcard, as they are going to be
+// created on the sink.
+func (m *Mirror) describeACLs(rootCtx context.Context, client *kadm.Client) ([]plannedACL, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	groups := make([]string, 0, len(m.opts.Groups))
+	for _, group := range m.opts.Groups {
+		groups = append(groups, group.Source)
+	}
+
+	// Without principals, hosts and operations the filter matches any.
+	builder := kadm.NewACLs().
+		Topics(m.names...).
+		MaybeGroups(groups...).
+		ResourcePatternType(kadm.ACLPatternMatch).
+		Allow().AllowHosts().
+		Deny().DenyHosts().
+		Operations()
+
+	results, err := client.DescribeACLs(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe source ACLs: %w", err)
+	}
+
+	return planACLs(results, m.renamed, m.opts.Groups, m.opts.PrincipalRewrites)
+}
+
+// planACLs turns the described source ACLs into the ACLs to create on the
+// sink: principals are rewritten, literal ACLs of renamed topics and groups
+// follow them, and ACLs matched by several filters are only kept once.
+// Prefixed ACLs keep their prefix, with a warning when it no longer covers
+// the name a topic or group is renamed to.
+func planACLs(results kadm.DescribeACLsResults, renamed map[string]string, groups []Group, rewrites []PrincipalRewrite) ([]plannedACL, error) {
+	sinkGroups := make(map[string]string, len(groups))
+	for _, group := range groups {
+		sinkGroups[group.Source] = group.SinkGroup()
+	}
+
+	principals := make(map[string]string, len(rewrites))
+	for _, rewrite := range rewrites {
+		principals[rewrite.From] = rewrite.To
+	}
+
+	seen := map[plannedACL]bool{}
+	acls := make([]plannedACL, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			return nil, fmt.Errorf("failed to describe source ACLs: %w", errors.Join(result.Err, errorMessage(result.ErrMessage)))
+		}
+
+		for _, described := range result.Described {
+			acl := plannedACL(described)
+			if to, ok := principals[acl.Principal]; ok {
+				acl.Principal = to
+			}
+			switch acl.Pattern {
+			case kadm.ACLPatternLiteral:
+				switch acl.Type {
+				case kmsg.ACLResourceTypeTopic:
+					if sinkTopic, ok := renamed[acl.Name]; ok {
+						acl.Name = sinkTopic
+					}
+				case kmsg.ACLResourceTypeGroup:
+					if sinkGroup, ok := sinkGroups[acl.Name]; ok {
+						acl.Name = sinkGroup
+					}
+				}
+			case kadm.ACLPatternPrefixed:
+				var uncovered []string
+				switch acl.Type {
+				case kmsg.ACLResourceTypeTopic:
+					uncovered = uncoveredByPrefix(acl.Name, renamed)
+				case kmsg.ACLResourceTypeGroup:
+					uncovered = uncoveredByPrefix(acl.Name, sinkGroups)
+				}
+				if len(uncovered) > 0 && !seen[acl] {
+					sideLogger(sideSink).Warn("Prefixed ACL is created unchanged and doesn't cover renamed resources",
+						slog.String("type", acl.Type.String()),
+						slog.String("prefix", acl.Name),
+						slog.String("principal", acl.Principal),
+						slog.String("operation", acl.Operation.String()),
+						slog.Any("renamed", uncovered),
+					)
+				}
+			}
+
+			if !seen[acl] {
+				seen[acl] = true
+				acls = append(acls, acl)
+			}
+		}
+	}
+
+	slices.SortFunc(acls, func(a, b plannedACL) int {
+		return cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Pattern, b.Pattern),
+			cmp.Compare(a.Principal, b.Principal),
+			cmp.Compare(a.Host, b.Host),
+			cmp.Compare(a.Operation, b.Operation),
+			cmp.Compare(a.Permission, b.Permission),
+		)
+	})
+	return acls, nil
+}
+
+// uncoveredByPrefix returns the names of renamed, mapped to their sink name,
+// that start with prefix while their sink name doesn't, in order.
+func uncoveredByPrefix(prefix string, renamed map[string]string) []string {
+	var uncovered []string
+	for _, name := range slices.Sorted(maps.Keys(renamed)) {
+		if strings.HasPrefix(name, prefix) && !strings.HasPrefix(renamed[name], prefix) {
+			uncovered = append(uncovered, name)
+		}
+	}
+	return uncovered
+}
+
+// errorMessage returns the extra message of a Kafka error as an error, nil
+// if there is none.
+func errorMessage(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
+
+// createACLs creates the planned ACLs on the sink. ACLs that already exist
+// are left as they are.
+func (m *Mirror) createACLs(rootCtx context.Context, client *kadm.Client, acls []plannedACL) error {
+	for _, acl := range acls {
+		if err := m.createACL(rootCtx, client, acl); err != nil {
+			return fmt.Errorf("failed to create ACL %s %s %s on %s %q for %s: %w",
+				acl.Permission, acl.Operation, acl.Pattern, acl.Type, acl.Name, acl.Principal, err)
+		}
+	}
+	return nil
+}
+
+func (m *Mirror) createACL(rootCtx context.Context, client *kadm.Client, acl plannedACL) error {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	builder := kadm.NewACLs().ResourcePatternType(acl.Pattern).Operations(acl.Operation)
+	switch acl.Type {
+	case kmsg.ACLResourceTypeTopic:
+		builder.Topics(acl.Name)
+	case kmsg.ACLResourceTypeGroup:
+		builder.Groups(acl.Name)
+	default:
+		return fmt.Errorf("unsupported resource type %s", acl.Type)
+	}
+
+	switch acl.Permission {
+	case kmsg.ACLPermissionTypeAllow:
+		builder.Allow(acl.Principal).AllowHosts(acl.Host)
+	case kmsg.ACLPermissionTypeDeny:
+		builder.Deny(acl.Principal).DenyHosts(acl.Host)
+	default:
+		return fmt.Errorf("unsupported permission %s", acl.Permission)
+	}
+
+	results, err := client.CreateACLs(ctx, builder)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return errors.Join(result.Err, errorMessage(result.ErrMessage))
+		}
+	}
+	return nil
+}
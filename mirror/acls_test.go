@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestParsePrincipalRewrite(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    PrincipalRewrite
+		wantErr bool
+	}{
+		{value: "User:orders-service=User:orders-local", want: PrincipalRewrite{From: "User:orders-service", To: "User:orders-local"}},
+		{value: "User:orders-service", wantErr: true},
+		{value: "=User:orders-local", wantErr: true},
+		{value: "User:orders-service=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePrincipalRewrite(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrincipalRewrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePrincipalRewrite() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanACLs(t *testing.T) {
+	readOrders := kadm.DescribedACL{
+		Principal:  "User:orders-service",
+		Host:       "*",
+		Type:       kmsg.ACLResourceTypeTopic,
+		Name:       "orders",
+		Pattern:    kadm.ACLPatternLiteral,
+		Operation:  kadm.OpRead,
+		Permission: kmsg.ACLPermissionTypeAllow,
+	}
+	readGroup := kadm.DescribedACL{
+		Principal:  "User:orders-service",
+		Host:       "*",
+		Type:       kmsg.ACLResourceTypeGroup,
+		Name:       "orders-service",
+		Pattern:    kadm.ACLPatternLiteral,
+		Operation:  kadm.OpRead,
+		Permission: kmsg.ACLPermissionTypeAllow,
+	}
+	writeUsers := readOrders
+	writeUsers.Name = "users"
+	writeUsers.Operation = kadm.OpWrite
+	prefixedGroup := readGroup
+	prefixedGroup.Name = "orders-"
+	prefixedGroup.Pattern = kadm.ACLPatternPrefixed
+	denyAll := kadm.DescribedACL{
+		Principal:  "User:*",
+		Host:       "10.0.0.1",
+		Type:       kmsg.ACLResourceTypeTopic,
+		Name:       "*",
+		Pattern:    kadm.ACLPatternLiteral,
+		Operation:  kadm.OpAll,
+		Permission: kmsg.ACLPermissionTypeDeny,
+	}
+
+	// The wildcard ACL matches both topic filters.
+	results := kadm.DescribeACLsResults{
+		{Described: kadm.DescribedACLs{readOrders, denyAll}},
+		{Described: kadm.DescribedACLs{writeUsers, denyAll}},
+		{Described: kadm.DescribedACLs{readGroup, prefixedGroup}},
+	}
+
+	got, err := planACLs(results,
+		map[string]string{"users": "eu.users"},
+		[]Group{{Source: "orders-service", Sink: "orders-local"}},
+		[]PrincipalRewrite{{From: "User:orders-service", To: "User:local"}},
+	)
+	if err != nil {
+		t.Fatalf("planACLs() error = %v", err)
+	}
+
+	want := []plannedACL{
+		{Principal: "User:*", Host: "10.0.0.1", Type: kmsg.ACLResourceTypeTopic, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpAll, Permission: kmsg.ACLPermissionTypeDeny},
+		{Principal: "User:local", Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "eu.users", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow},
+		{Principal: "User:local", Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "orders", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow},
+		// A prefixed ACL isn't specific to the group, so it keeps its name.
+		{Principal: "User:local", Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "orders-", Pattern: kadm.ACLPatternPrefixed, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow},
+		{Principal: "User:local", Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "orders-local", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("planACLs() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("planACLs()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPlanACLs_Error(t *testing.T) {
+	results := kadm.DescribeACLsResults{{Err: kerr.SecurityDisabled, ErrMessage: "no authorizer is configured"}}
+	if _, err := planACLs(results, nil, nil, nil); !errors.Is(err, kerr.SecurityDisabled) {
+		t.Errorf("planACLs() error = %v, want %v", err, kerr.SecurityDisabled)
+	}
+}
+
+func TestUncoveredByPrefix(t *testing.T) {
+	renamed := map[string]string{
+		"orders":    "eu.orders",
+		"orders-v2": "orders-v2-local",
+		"users":     "eu.users",
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"orders", []string{"orders"}},
+		{"orders-", nil},
+		{"eu.", nil},
+		{"", nil},
+		{"u", []string{"users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := uncoveredByPrefix(tt.prefix, renamed); !slices.Equal(got, tt.want) {
+				t.Errorf("uncoveredByPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func getClients(opts []kgo.Opt) (*kgo.Client, *kadm.Client, error) {
+	if opts == nil {
+		return nil, nil, fmt.Errorf("no brokers configured")
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create source admin client: %w", err)
+	}
+
+	return client, kadm.NewClient(client), nil
+}
+
+func wait(timeout time.Duration, fn func() bool) error {
+	start := time.Now()
+	for !fn() {
+		if time.Since(start) > timeout {
+			return &TimeoutError{Err: fmt.Errorf("timeout waiting for condition")}
+		}
+		time.Sleep(time.Second)
+	}
+	return nil
+}
+
+func (m *Mirror) getTopics(rootCtx context.Context, client *kadm.Client, names []string) (kadm.TopicDetails, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	topics, err := client.ListTopics(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	return topics, nil
+}
+
+func (m *Mirror) getWatermarks(rootCtx context.Context, client *kadm.Client, committed bool, topics ...string) (map[string]map[int32]Watermark, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	if committed {
+		return ListCommittedWatermarks(ctx, client, topics...)
+	}
+	return ListWatermarks(ctx, client, topics...)
+}
+
+func (m *Mirror) deleteExistingTopics(rootCtx context.Context, client *kadm.Client, topicsToDelete []string) error {
+	if len(topicsToDelete) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	if _, err := client.DeleteTopics(ctx, topicsToDelete...); err != nil {
+		return fmt.Errorf("failed to delete topic %v: %w", topicsToDelete, err)
+	}
+
+	if err := wait(m.opts.Timeout, func() bool {
+		ctx, cancel = context.WithTimeout(rootCtx, m.opts.Timeout)
+		defer cancel()
+
+		sinkTopics, err := client.ListTopics(ctx, topicsToDelete...)
+		if err != nil {
+			sideLogger(sideSink).Error("Failed to list topics to check if they are deleted", slog.Any("error", err))
+			return false
+		}
+
+		for _, topic := range topicsToDelete {
+			if sinkTopics.Has(topic) {
+				return false
+			}
+		}
+
+		return true
+	}); err != nil {
+		return fmt.Errorf("wait for topics deletion: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) createTopics(rootCtx context.Context, client *kadm.Client, topics []plannedTopic) error {
+	for _, topic := range topics {
+		if topic.Existing {
+			continue
+		}
+		if err := m.createTopic(rootCtx, client, topic); err != nil {
+			return fmt.Errorf("createTopic %q: %w", topic.sinkTopic(), err)
+		}
+	}
+
+	if err := wait(m.opts.Timeout, func() bool {
+		ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+		defer cancel()
+
+		sinkTopics, err := client.ListTopics(ctx, m.sinkNames...)
+		if err != nil {
+			sideLogger(sideSink).Error("Failed to list topics to check if they are created", slog.Any("error", err))
+			return false
+		}
+
+		for _, topic := range m.sinkNames {
+			if !sinkTopics.Has(topic) {
+				return false
+			}
+		}
+
+		return true
+	}); err != nil {
+		return fmt.Errorf("wait for topics creation: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) createTopic(rootCtx context.Context, client *kadm.Client, topic plannedTopic) error {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	if _, err := client.CreateTopic(ctx, topic.Partitions, topic.ReplicationFactor, topic.Configs, topic.sinkTopic()); err != nil {
+		return fmt.Errorf("failed to create topic %q: %w", topic.sinkTopic(), err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
-package main
+package mirror
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -102,3 +103,11 @@ func BenchmarkWait(b *testing.B) {
 		_ = wait(time.Second, fn)
 	}
 }
+
+func TestWait_TimeoutError(t *testing.T) {
+	err := wait(-1*time.Second, func() bool { return false })
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("wait() error = %v, want a timeout error", err)
+	}
+}
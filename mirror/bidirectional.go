@@ -0,0 +1,115 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Cluster is one of the two clusters of a Bidirectional and what is mirrored
+// from it to the other.
+type Cluster struct {
+	// Name identifies the cluster. It is the ProvenanceHeader of the records
+	// mirrored from it, so they aren't mirrored back.
+	Name string
+	// Client holds the options of its Kafka client, as Options.Source.
+	Client []kgo.Opt
+	// Topics is the allow-list of topics mirrored to the other cluster.
+	Topics []Topic
+}
+
+// Bidirectional mirrors topics between two clusters in both directions, with
+// a Mirror per direction. Every mirrored record is tagged with the name of
+// the cluster it comes from, and records that came from the other cluster
+// aren't mirrored back to it.
+type Bidirectional struct {
+	// directions labels the mirror of every direction in errors.
+	directions []string
+	mirrors    []*Mirror
+	dryRun     bool
+	progress   *progressMerger
+}
+
+// NewBidirectional returns a Bidirectional mirroring the topics of a to b and
+// the topics of b to a, or a *ConfigError if they are invalid. The Source,
+// Sink, Topics and Provenance of opts are replaced by the ones of every
+// direction; its other options apply to both, and its per topic options to
+// the topics of either cluster. Sink topics that exist are kept rather than
+// recreated, as they may hold records of the other side. The mirror of
+// every direction has its own transactional ID, the one of opts suffixed
+// with a dot and the name of its source cluster. Sinks, Groups, MetricsAddr,
+// ControlAddr and OffsetMapFile aren't supported.
+func NewBidirectional(opts Options, a, b Cluster) (*Bidirectional, error) {
+	switch {
+	case a.Name == "" || b.Name == "":
+		return nil, &ConfigError{Err: fmt.Errorf("cluster without a name")}
+	case a.Name == b.Name:
+		return nil, &ConfigError{Err: fmt.Errorf("both clusters are named %q", a.Name)}
+	case len(a.Topics) == 0 && len(b.Topics) == 0:
+		return nil, &ConfigError{Err: fmt.Errorf("no topics specified")}
+	case len(opts.Sinks) > 0:
+		return nil, &ConfigError{Err: fmt.Errorf("several sinks aren't supported in both directions")}
+	case opts.MetricsAddr != "":
+		return nil, &ConfigError{Err: fmt.Errorf("metrics aren't supported in both directions")}
+	case opts.ControlAddr != "":
+		return nil, &ConfigError{Err: fmt.Errorf("control endpoints aren't supported in both directions")}
+	case opts.OffsetMapFile != "":
+		return nil, &ConfigError{Err: fmt.Errorf("offset map file isn't supported in both directions")}
+	case len(opts.Groups) > 0:
+		return nil, &ConfigError{Err: fmt.Errorf("consumer groups aren't supported in both directions")}
+	}
+
+	d := &Bidirectional{dryRun: opts.DryRun}
+	if opts.Progress != nil {
+		d.progress = &progressMerger{snapshots: make([][]PartitionProgress, 2)}
+	}
+
+	for i, from := range []Cluster{a, b} {
+		to := b
+		if i == 1 {
+			to = a
+		}
+		// A direction may have nothing to mirror, e.g. to only receive
+		// the records of a shared cluster.
+		if len(from.Topics) == 0 {
+			continue
+		}
+
+		directionOpts := withTopics(opts, from.Topics)
+		directionOpts.Source = from.Client
+		directionOpts.Sink = to.Client
+		directionOpts.Provenance = from.Name
+		directionOpts.ExcludeOrigin = to.Name
+		directionOpts.KeepSinkTopics = true
+		if directionOpts.ExactlyOnce.TransactionalID != "" {
+			directionOpts.ExactlyOnce.TransactionalID += "." + from.Name
+		}
+
+		label := from.Name + " to " + to.Name
+		m, err := New(directionOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+		if d.progress != nil {
+			m.opts.Progress = d.progress.collect(i, func(topic string) string {
+				return from.Name + ":" + topic
+			})
+		}
+
+		d.directions = append(d.directions, label)
+		d.mirrors = append(d.mirrors, m)
+	}
+
+	if d.progress != nil {
+		d.progress.report = opts.Progress
+		d.progress.interval = d.mirrors[0].opts.ProgressInterval
+	}
+	return d, nil
+}
+
+// Run runs the mirror of both directions until ctx is done, or both stop.
+// The first mirror failing stops the other.
+func (d *Bidirectional) Run(ctx context.Context) error {
+	return runMirrors(ctx, d.directions, d.mirrors, d.dryRun, d.progress)
+}
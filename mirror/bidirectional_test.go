@@ -0,0 +1,144 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestNewBidirectional_Invalid(t *testing.T) {
+	local := Cluster{Name: "local", Topics: []Topic{{Name: "orders"}}}
+	dev := Cluster{Name: "dev", Topics: []Topic{{Name: "orders"}}}
+
+	tests := []struct {
+		name string
+		opts Options
+		a, b Cluster
+	}{
+		{name: "unnamed cluster", a: local, b: Cluster{Topics: dev.Topics}},
+		{name: "same name", a: local, b: Cluster{Name: "local", Topics: dev.Topics}},
+		{name: "no topics", a: Cluster{Name: "local"}, b: Cluster{Name: "dev"}},
+		{name: "sinks", opts: Options{Sinks: []Sink{{Name: "alice"}}}, a: local, b: dev},
+		{name: "metrics", opts: Options{MetricsAddr: ":9090"}, a: local, b: dev},
+		{name: "offset map", opts: Options{OffsetMapFile: "offsets.json"}, a: local, b: dev},
+		{name: "groups", opts: Options{Groups: []Group{{Source: "orders-service"}}}, a: local, b: dev},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBidirectional(tt.opts, tt.a, tt.b)
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Errorf("NewBidirectional() error = %v, want a *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestNewBidirectional_Directions(t *testing.T) {
+	local := Cluster{Name: "local", Topics: []Topic{{Name: "test-events"}}}
+	dev := Cluster{Name: "dev", Topics: []Topic{{Name: "orders"}}}
+
+	opts := Options{SinkPartitions: map[string]int32{"orders": 2}}
+	d, err := NewBidirectional(opts, local, dev)
+	if err != nil {
+		t.Fatalf("NewBidirectional() error = %v", err)
+	}
+
+	if len(d.mirrors) != 2 {
+		t.Fatalf("NewBidirectional() mirrors = %d, want 2", len(d.mirrors))
+	}
+	toDev, toLocal := d.mirrors[0].opts, d.mirrors[1].opts
+	if toDev.Provenance != "local" || toDev.ExcludeOrigin != "dev" {
+		t.Errorf("local to dev provenance = %q excluding %q, want local excluding dev", toDev.Provenance, toDev.ExcludeOrigin)
+	}
+	if toLocal.Provenance != "dev" || toLocal.ExcludeOrigin != "local" {
+		t.Errorf("dev to local provenance = %q excluding %q, want dev excluding local", toLocal.Provenance, toLocal.ExcludeOrigin)
+	}
+	if !toDev.KeepSinkTopics || !toLocal.KeepSinkTopics {
+		t.Errorf("KeepSinkTopics = %v and %v, want both set", toDev.KeepSinkTopics, toLocal.KeepSinkTopics)
+	}
+	if toDev.SinkPartitions != nil || len(toLocal.SinkPartitions) != 1 {
+		t.Errorf("sink partitions = %v and %v, want only orders from dev", toDev.SinkPartitions, toLocal.SinkPartitions)
+	}
+
+	// A cluster without topics only receives the records of the other.
+	d, err = NewBidirectional(Options{}, Cluster{Name: "local"}, dev)
+	if err != nil {
+		t.Fatalf("NewBidirectional() error = %v", err)
+	}
+	if len(d.mirrors) != 1 || d.directions[0] != "dev to local" {
+		t.Errorf("NewBidirectional() directions = %v, want [dev to local]", d.directions)
+	}
+}
+
+func TestBidirectional(t *testing.T) {
+	skipShort(t)
+
+	local := newFakeCluster(t, 1, "orders")
+	dev := newFakeCluster(t, 1, "orders")
+
+	produceValues(t, local, "orders", "local-order")
+	produceValues(t, dev, "orders", "dev-order")
+
+	topics, err := ParseTopics([]string{"orders@-2"})
+	if err != nil {
+		t.Fatalf("ParseTopics() error = %v", err)
+	}
+
+	d, err := NewBidirectional(Options{Timeout: 5 * time.Second},
+		Cluster{Name: "local", Client: []kgo.Opt{kgo.SeedBrokers(local...)}, Topics: topics},
+		Cluster{Name: "dev", Client: []kgo.Opt{kgo.SeedBrokers(dev...)}, Topics: topics},
+	)
+	if err != nil {
+		t.Fatalf("NewBidirectional() error = %v", err)
+	}
+
+	stop := startMirror(t, d)
+	gotLocal := consumeRecords(t, local, 2, "orders")
+	gotDev := consumeRecords(t, dev, 2, "orders")
+
+	// Give the mirrored records time to be mirrored back, if they were.
+	time.Sleep(time.Second)
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{"dev-order", "local-order"}
+	for name, got := range map[string][]*kgo.Record{"local": gotLocal, "dev": gotDev} {
+		if values := recordValues(got); len(values) != 2 || values[0] != want[0] || values[1] != want[1] {
+			t.Errorf("records on %s = %v, want %v", name, values, want)
+		}
+	}
+
+	for name, brokers := range map[string][]string{"local": local, "dev": dev} {
+		if end := endOffset(t, brokers, "orders"); end != 2 {
+			t.Errorf("end offset of orders on %s = %d, want 2, records were mirrored back", name, end)
+		}
+	}
+}
+
+// endOffset returns the end offset of partition 0 of topic.
+func endOffset(t *testing.T, brokers []string, topic string) int64 {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	offsets, err := kadm.NewClient(client).ListEndOffsets(context.Background(), topic)
+	if err != nil {
+		t.Fatalf("ListEndOffsets() error = %v", err)
+	}
+	o, ok := offsets.Lookup(topic, 0)
+	if !ok || o.Err != nil {
+		t.Fatalf("ListEndOffsets() of %s = %+v", topic, o)
+	}
+	return o.Offset
+}
@@ -0,0 +1,144 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// codecNames are the names of the compression types of record batches.
+var codecNames = [...]string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// sourceCodec returns the compression codec of the batch r was fetched in.
+func sourceCodec(r *kgo.Record) (kgo.CompressionCodec, error) {
+	switch compression := r.Attrs.CompressionType(); compression {
+	case 0:
+		return kgo.NoCompression(), nil
+	case 1:
+		return kgo.GzipCompression(), nil
+	case 2:
+		return kgo.SnappyCompression(), nil
+	case 3:
+		return kgo.Lz4Compression(), nil
+	case 4:
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, fmt.Errorf("unknown compression type %d", compression)
+	}
+}
+
+// codecProducer produces records with the compression codec of their source
+// batch. A client compresses every batch with the same codec, so there is one
+// sink client per codec in use, created on demand. It is safe for concurrent
+// use.
+type codecProducer struct {
+	opts []kgo.Opt
+	// metrics and side are the client metrics of the sink, the ones of every
+	// codec under the side suffixed with the codec name.
+	metrics *mirrorMetrics
+	side    string
+
+	mu      sync.Mutex
+	clients map[uint8]*kgo.Client
+	// partitions holds the compression type last used for each source
+	// partition, as every partition is produced by its own worker.
+	partitions map[topicPartition]uint8
+}
+
+func newCodecProducer(opts []kgo.Opt, metrics *mirrorMetrics, side string) *codecProducer {
+	return &codecProducer{
+		opts:       opts,
+		metrics:    metrics,
+		side:       side,
+		clients:    map[uint8]*kgo.Client{},
+		partitions: map[topicPartition]uint8{},
+	}
+}
+
+// client returns the sink client producing r with the codec of its source
+// batch. When the codec of a partition changes, the client used so far is
+// flushed first so records of the partition aren't reordered across clients.
+func (p *codecProducer) client(ctx context.Context, r *kgo.Record) (*kgo.Client, error) {
+	compression := r.Attrs.CompressionType()
+
+	tp := topicPartition{Topic: r.Topic, Partition: r.Partition}
+
+	p.mu.Lock()
+	var previous *kgo.Client
+	if c, ok := p.partitions[tp]; ok && c != compression {
+		previous = p.clients[c]
+	}
+	p.partitions[tp] = compression
+	client, err := p.clientOf(r)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The previous client is flushed without the lock, so records of other
+	// partitions are produced meanwhile.
+	if previous != nil {
+		if err := previous.Flush(ctx); err != nil {
+			return nil, fmt.Errorf("failed to flush records before changing codec of partition %d of topic %q: %w", tp.Partition, tp.Topic, err)
+		}
+	}
+	return client, nil
+}
+
+// clientOf returns the client of the codec of r, created if missing. p.mu
+// must be held.
+func (p *codecProducer) clientOf(r *kgo.Record) (*kgo.Client, error) {
+	compression := r.Attrs.CompressionType()
+	if client, ok := p.clients[compression]; ok {
+		return client, nil
+	}
+
+	codec, err := sourceCodec(r)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := slices.Concat(
+		p.opts,
+		[]kgo.Opt{kgo.ProducerBatchCompression(codec)},
+		p.metrics.clientOpts(p.side+"_codec_"+codecNames[compression]),
+	)
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sink Kafka client: %w", err)
+	}
+	p.clients[compression] = client
+	return client, nil
+}
+
+// Flush waits for the records of every client to be produced. The clients
+// are flushed without the lock, so records go on being produced meanwhile.
+func (p *codecProducer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	clients := make([]*kgo.Client, 0, len(p.clients))
+	for _, compression := range slices.Sorted(maps.Keys(p.clients)) {
+		clients = append(clients, p.clients[compression])
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, client := range clients {
+		errs = append(errs, client.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every client.
+func (p *codecProducer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, client := range p.clients {
+		client.Close()
+	}
+}
@@ -0,0 +1,370 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeClusters numbers the fake clusters, so each has its own cluster ID.
+var fakeClusters atomic.Int64
+
+// newFakeCluster starts an in-process Kafka cluster with the given topics and
+// returns its seed brokers.
+func newFakeCluster(tb testing.TB, partitions int32, topics ...string) []string {
+	tb.Helper()
+
+	cluster, err := kfake.NewCluster(
+		kfake.NumBrokers(1),
+		kfake.ClusterID(fmt.Sprintf("kfake-%d", fakeClusters.Add(1))),
+		kfake.SeedTopics(partitions, topics...),
+	)
+	if err != nil {
+		tb.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	tb.Cleanup(cluster.Close)
+
+	return cluster.ListenAddrs()
+}
+
+// produceRecords produces n compressible records to topic with codec.
+func produceRecords(tb testing.TB, brokers []string, topic string, n int, codec kgo.CompressionCodec) {
+	tb.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.ProducerBatchCompression(codec))
+	if err != nil {
+		tb.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	value := bytes.Repeat([]byte("kmir"), 256)
+	records := make([]*kgo.Record, n)
+	for i := range records {
+		records[i] = &kgo.Record{Topic: topic, Key: fmt.Appendf(nil, "key-%d", i), Value: value}
+	}
+
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		tb.Fatalf("ProduceSync() error = %v", err)
+	}
+}
+
+// consumeRecords consumes n records of topics from their start.
+func consumeRecords(tb testing.TB, brokers []string, n int, topics ...string) []*kgo.Record {
+	tb.Helper()
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumeTopics(topics...),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		tb.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			tb.Fatalf("PollFetches() error = %v after %d of %d records", err, len(records), n)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func TestSourceCodec(t *testing.T) {
+	brokers := newFakeCluster(t, 1, "none", "gzip", "zstd")
+	produceRecords(t, brokers, "none", 1, kgo.NoCompression())
+	produceRecords(t, brokers, "gzip", 1, kgo.GzipCompression())
+	produceRecords(t, brokers, "zstd", 1, kgo.ZstdCompression())
+
+	for _, r := range consumeRecords(t, brokers, 3, "none", "gzip", "zstd") {
+		codec, err := sourceCodec(r)
+		if err != nil {
+			t.Fatalf("sourceCodec() error = %v", err)
+		}
+
+		want := map[string]kgo.CompressionCodec{
+			"none": kgo.NoCompression(),
+			"gzip": kgo.GzipCompression(),
+			"zstd": kgo.ZstdCompression(),
+		}[r.Topic]
+		if codec != want {
+			t.Errorf("sourceCodec() of %s = %+v, want %+v", r.Topic, codec, want)
+		}
+	}
+}
+
+func TestCodecProducer(t *testing.T) {
+	source := newFakeCluster(t, 1, "none", "zstd")
+	sink := newFakeCluster(t, 1, "none", "zstd")
+	produceRecords(t, source, "none", 5, kgo.NoCompression())
+	produceRecords(t, source, "zstd", 5, kgo.ZstdCompression())
+
+	metrics := newMirrorMetrics()
+	p := newCodecProducer([]kgo.Opt{kgo.SeedBrokers(sink...)}, metrics, sideSink)
+	defer p.Close()
+
+	ctx := context.Background()
+	for _, r := range consumeRecords(t, source, 10, "none", "zstd") {
+		client, err := p.client(ctx, r)
+		if err != nil {
+			t.Fatalf("client() error = %v", err)
+		}
+		client.Produce(ctx, r, func(_ *kgo.Record, err error) {
+			if err != nil {
+				t.Errorf("Produce() error = %v", err)
+			}
+		})
+	}
+
+	if err := p.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(p.clients) != 2 {
+		t.Errorf("codecProducer created %d clients, want 2", len(p.clients))
+	}
+
+	// Every codec client has the client metrics of the sink, under its own
+	// subsystem.
+	families, err := metrics.registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	for _, codec := range []string{"none", "zstd"} {
+		if name := metricsNamespace + "_sink_codec_" + codec + "_connects_total"; !names[name] {
+			t.Errorf("metric %s is missing", name)
+		}
+	}
+
+	for _, r := range consumeRecords(t, sink, 10, "none", "zstd") {
+		want := map[string]uint8{"none": 0, "zstd": 4}[r.Topic]
+		if got := r.Attrs.CompressionType(); got != want {
+			t.Errorf("sink compression type of %s = %d, want %d", r.Topic, got, want)
+		}
+	}
+}
+
+// switchingCodecRecords returns the records of every partition of orders on
+// source, in batches alternating between uncompressed and zstd records
+// valued by their position in the partition.
+func switchingCodecRecords(t *testing.T, source []string, partitions int32, rounds, batch int) map[int32][]*kgo.Record {
+	t.Helper()
+
+	producers := make([]*kgo.Client, 2)
+	for i, codec := range []kgo.CompressionCodec{kgo.NoCompression(), kgo.ZstdCompression()} {
+		client, err := kgo.NewClient(
+			kgo.SeedBrokers(source...),
+			kgo.RecordPartitioner(kgo.ManualPartitioner()),
+			kgo.ProducerBatchCompression(codec),
+		)
+		if err != nil {
+			t.Fatalf("kgo.NewClient() error = %v", err)
+		}
+		defer client.Close()
+		producers[i] = client
+	}
+
+	for round := range rounds {
+		for partition := range partitions {
+			records := make([]*kgo.Record, batch)
+			for i := range records {
+				value := fmt.Appendf(nil, "%03d", round*batch+i)
+				records[i] = &kgo.Record{Topic: "orders", Partition: partition, Value: bytes.Repeat(value, 100)}
+			}
+			if err := producers[round%2].ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+				t.Fatalf("ProduceSync() error = %v", err)
+			}
+		}
+	}
+
+	byPartition := map[int32][]*kgo.Record{}
+	for _, r := range consumeRecords(t, source, int(partitions)*rounds*batch, "orders") {
+		byPartition[r.Partition] = append(byPartition[r.Partition], r)
+	}
+	return byPartition
+}
+
+// checkSinkOrder checks that the n records of orders on sink are in the
+// order of their source partition.
+func checkSinkOrder(t *testing.T, sink []string, n int) {
+	t.Helper()
+
+	last := map[int32]string{}
+	for _, r := range consumeRecords(t, sink, n, "orders") {
+		value := string(r.Value[:3])
+		if previous, ok := last[r.Partition]; ok && value <= previous {
+			t.Errorf("record %s of partition %d at sink offset %d follows record %s", value, r.Partition, r.Offset, previous)
+		}
+		last[r.Partition] = value
+	}
+}
+
+func TestCodecProducer_PartitionsSwitchingCodecs(t *testing.T) {
+	const (
+		partitions = 4
+		rounds     = 6
+		batch      = 5
+	)
+
+	source := newFakeCluster(t, partitions, "orders")
+	sink := newFakeCluster(t, partitions, "orders")
+	byPartition := switchingCodecRecords(t, source, partitions, rounds, batch)
+
+	p := newCodecProducer([]kgo.Opt{
+		kgo.SeedBrokers(sink...),
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+		kgo.ProducerLinger(20 * time.Millisecond),
+	}, nil, sideSink)
+	defer p.Close()
+
+	// Every partition is produced by its own worker, as in the pipeline.
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for _, records := range byPartition {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, r := range records {
+				client, err := p.client(ctx, r)
+				if err != nil {
+					t.Errorf("client() error = %v", err)
+					return
+				}
+				client.Produce(ctx, r, func(_ *kgo.Record, err error) {
+					if err != nil {
+						t.Errorf("Produce() error = %v", err)
+					}
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := p.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	checkSinkOrder(t, sink, partitions*rounds*batch)
+}
+
+func TestCodecProducer_InterleavedSwitch(t *testing.T) {
+	source := newFakeCluster(t, 2, "orders")
+	sink := newFakeCluster(t, 2, "orders")
+	byPartition := switchingCodecRecords(t, source, 2, 2, 1)
+
+	// Nothing is sent until flushed.
+	p := newCodecProducer([]kgo.Opt{
+		kgo.SeedBrokers(sink...),
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+		kgo.ProducerLinger(time.Minute),
+	}, nil, sideSink)
+	defer p.Close()
+
+	ctx := context.Background()
+	client := func(r *kgo.Record) *kgo.Client {
+		c, err := p.client(ctx, r)
+		if err != nil {
+			t.Fatalf("client() error = %v", err)
+		}
+		return c
+	}
+
+	// Partition 1 gets the uncompressed client, then partition 0 switches
+	// to zstd before partition 1 produced to it.
+	uncompressed := client(byPartition[1][0])
+	client(byPartition[0][0]).Produce(ctx, byPartition[0][0], nil)
+	client(byPartition[0][1]).Produce(ctx, byPartition[0][1], nil)
+	uncompressed.Produce(ctx, byPartition[1][0], nil)
+
+	// The switch of partition 1 sends its uncompressed record first, even if
+	// the zstd client is flushed before the uncompressed one.
+	zstd := client(byPartition[1][1])
+	zstd.Produce(ctx, byPartition[1][1], nil)
+	if err := zstd.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := p.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	checkSinkOrder(t, sink, 4)
+}
+
+// BenchmarkProduceCompression compares producing records fetched from
+// batches of a given codec with the default sink codec and with the codec of
+// the source batch.
+func BenchmarkProduceCompression(b *testing.B) {
+	const records = 1000
+
+	for _, source := range []struct {
+		name  string
+		codec kgo.CompressionCodec
+	}{
+		{"none", kgo.NoCompression()},
+		{"zstd", kgo.ZstdCompression()},
+	} {
+		brokers := newFakeCluster(b, 1, source.name)
+		produceRecords(b, brokers, source.name, records, source.codec)
+		fetched := consumeRecords(b, brokers, records, source.name)
+
+		for _, preserve := range []bool{false, true} {
+			name := fmt.Sprintf("source=%s/preserve=%v", source.name, preserve)
+			b.Run(name, func(b *testing.B) {
+				sink := newFakeCluster(b, 1, source.name)
+
+				var client *kgo.Client
+				var codecs *codecProducer
+				if preserve {
+					codecs = newCodecProducer([]kgo.Opt{kgo.SeedBrokers(sink...)}, nil, sideSink)
+					defer codecs.Close()
+				} else {
+					var err error
+					if client, err = kgo.NewClient(kgo.SeedBrokers(sink...)); err != nil {
+						b.Fatalf("kgo.NewClient() error = %v", err)
+					}
+					defer client.Close()
+				}
+
+				ctx := context.Background()
+				b.SetBytes(int64(len(fetched[0].Value) * records))
+				for b.Loop() {
+					for _, r := range fetched {
+						out := &kgo.Record{Topic: r.Topic, Key: r.Key, Value: r.Value, Attrs: r.Attrs}
+						c := client
+						if codecs != nil {
+							var err error
+							if c, err = codecs.client(ctx, out); err != nil {
+								b.Fatalf("client() error = %v", err)
+							}
+						}
+						c.Produce(ctx, out, nil)
+					}
+
+					var err error
+					if codecs != nil {
+						err = codecs.Flush(ctx)
+					} else {
+						err = client.Flush(ctx)
+					}
+					if err != nil {
+						b.Fatalf("Flush() error = %v", err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package mirror
+
+import "fmt"
+
+// ConfigError is returned when the options of a mirror are invalid.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// MissingTopicError is returned when topics to mirror don't exist on the
+// source.
+type MissingTopicError struct {
+	Topics []string
+}
+
+func (e *MissingTopicError) Error() string {
+	return fmt.Sprintf("source topics missing: %v", e.Topics)
+}
+
+// SinkWriteError is returned when records or topics can't be written to the
+// sink.
+type SinkWriteError struct {
+	Err error
+}
+
+func (e *SinkWriteError) Error() string { return e.Err.Error() }
+func (e *SinkWriteError) Unwrap() error { return e.Err }
+
+// TimeoutError is returned when a cluster doesn't reach the expected state
+// in time.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string { return e.Err.Error() }
+func (e *TimeoutError) Unwrap() error { return e.Err }
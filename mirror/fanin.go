@@ -0,0 +1,286 @@
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Source is a source cluster of a FanIn and what is mirrored from it.
+type Source struct {
+	// Name identifies the cluster. It is the ProvenanceHeader of its records
+	// and the prefix of its colliding topics.
+	Name string
+	// Client holds the options of its Kafka client, as Options.Source.
+	Client []kgo.Opt
+	Topics []Topic
+	Groups []Group
+}
+
+// FanIn mirrors topics of several source clusters into one sink, with a
+// Mirror per source.
+type FanIn struct {
+	// sources labels the mirror of every source in errors.
+	sources  []string
+	mirrors  []*Mirror
+	dryRun   bool
+	progress *progressMerger
+}
+
+// NewFanIn returns a FanIn mirroring sources into the sink of opts, or a
+// *ConfigError if they are invalid. The Source, Topics, Groups and
+// Provenance of opts are replaced by the ones of every source; its other
+// options apply to every source, and its per topic options to the topics of
+// any source. MetricsAddr, ControlAddr and OffsetMapFile aren't supported.
+// The mirror of every source has its own transactional ID, the one of opts
+// suffixed with a dot and the name of the source.
+//
+// Topics of several sources mirrored to the same sink topic collide. With
+// collisions fail, the default, they are refused; with prefix, they are
+// mirrored to sink topics prefixed with the name of their source and a dot,
+// e.g. eu.orders.
+func NewFanIn(opts Options, sources []Source, collisions string) (*FanIn, error) {
+	if len(sources) == 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("no sources specified")}
+	}
+
+	switch {
+	case opts.MetricsAddr != "":
+		return nil, &ConfigError{Err: fmt.Errorf("metrics aren't supported with several sources")}
+	case opts.ControlAddr != "":
+		return nil, &ConfigError{Err: fmt.Errorf("control endpoints aren't supported with several sources")}
+	case opts.OffsetMapFile != "":
+		return nil, &ConfigError{Err: fmt.Errorf("offset map file isn't supported with several sources")}
+	case len(opts.Groups) > 0:
+		return nil, &ConfigError{Err: fmt.Errorf("consumer groups are given per source with several sources")}
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, &ConfigError{Err: fmt.Errorf("source without a name")}
+		}
+		if names[source.Name] {
+			return nil, &ConfigError{Err: fmt.Errorf("several sources are named %q", source.Name)}
+		}
+		names[source.Name] = true
+	}
+
+	topics, err := resolveCollisions(sources, collisions)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	f := &FanIn{dryRun: opts.DryRun}
+	if opts.Progress != nil {
+		f.progress = &progressMerger{snapshots: make([][]PartitionProgress, len(sources))}
+	}
+
+	// Deletions are confirmed one source at a time, so prompts don't
+	// interleave.
+	var confirmMu sync.Mutex
+	confirm := opts.ConfirmDeletion
+	if confirm != nil {
+		opts.ConfirmDeletion = func(sink string, topics []string) (bool, error) {
+			confirmMu.Lock()
+			defer confirmMu.Unlock()
+			return confirm(sink, topics)
+		}
+	}
+
+	for i, source := range sources {
+		sourceOpts := withTopics(opts, topics[i])
+		sourceOpts.Source = source.Client
+		sourceOpts.Groups = source.Groups
+		sourceOpts.Provenance = source.Name
+		if sourceOpts.ExactlyOnce.TransactionalID != "" {
+			sourceOpts.ExactlyOnce.TransactionalID += "." + source.Name
+		}
+
+		m, err := New(sourceOpts)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", source.Name, err)
+		}
+		if f.progress != nil {
+			m.opts.Progress = f.progress.collect(i, m.sinkTopic)
+		}
+
+		f.sources = append(f.sources, "source "+source.Name)
+		f.mirrors = append(f.mirrors, m)
+	}
+
+	if f.progress != nil {
+		f.progress.report = opts.Progress
+		f.progress.interval = f.mirrors[0].opts.ProgressInterval
+	}
+	return f, nil
+}
+
+// resolveCollisions returns the topics of every source, renamed as
+// collisions requires.
+func resolveCollisions(sources []Source, collisions string) ([][]Topic, error) {
+	counts := map[string]int{}
+	for _, source := range sources {
+		for _, topic := range source.Topics {
+			counts[topic.SinkName()]++
+		}
+	}
+
+	prefix := false
+	switch collisions {
+	case "", "fail":
+	case "prefix":
+		prefix = true
+	default:
+		return nil, fmt.Errorf("unknown topic collision handling %q", collisions)
+	}
+
+	topics := make([][]Topic, len(sources))
+	sinkNames := map[string]string{}
+	for i, source := range sources {
+		for _, topic := range source.Topics {
+			if counts[topic.SinkName()] > 1 {
+				if !prefix {
+					return nil, fmt.Errorf("sink topic %q is mirrored from several sources", topic.SinkName())
+				}
+				topic.Sink = source.Name + "." + topic.SinkName()
+			}
+
+			// A prefixed topic may still collide with a topic of that name.
+			if other, ok := sinkNames[topic.SinkName()]; ok && other != source.Name {
+				return nil, fmt.Errorf("sink topic %q is mirrored from sources %s and %s", topic.SinkName(), other, source.Name)
+			}
+			sinkNames[topic.SinkName()] = source.Name
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// withTopics returns opts mirroring topics, without the per topic options
+// of other topics.
+func withTopics(opts Options, topics []Topic) Options {
+	opts.Topics = topics
+	opts.SinkPartitions = onlyTopics(opts.SinkPartitions, topics)
+	opts.Latest.Topics = slices.DeleteFunc(slices.Clone(opts.Latest.Topics), func(topic string) bool {
+		return !slices.Contains(TopicNames(topics), topic)
+	})
+	return opts
+}
+
+// onlyTopics returns the entries of byTopic of the given topics, nil if
+// there is none.
+func onlyTopics[V any](byTopic map[string]V, topics []Topic) map[string]V {
+	var out map[string]V
+	for _, topic := range topics {
+		if v, ok := byTopic[topic.Name]; ok {
+			if out == nil {
+				out = map[string]V{}
+			}
+			out[topic.Name] = v
+		}
+	}
+	return out
+}
+
+// Run runs the mirror of every source until ctx is done, or every mirror
+// stops. The first mirror failing stops the others. Dry-run plans are
+// written one source after the other.
+func (f *FanIn) Run(rootCtx context.Context) error {
+	return runMirrors(rootCtx, f.sources, f.mirrors, f.dryRun, f.progress)
+}
+
+// runMirrors runs mirrors, labelled by labels in errors, until ctx is done
+// or every mirror stops. The first mirror failing stops the others. Dry runs
+// are run one after the other, so their plans don't interleave.
+func runMirrors(rootCtx context.Context, labels []string, mirrors []*Mirror, dryRun bool, progress *progressMerger) error {
+	if dryRun {
+		for i, m := range mirrors {
+			if err := m.Run(rootCtx); err != nil {
+				return fmt.Errorf("%s: %w", labels[i], err)
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(rootCtx)
+	defer cancel()
+
+	if progress != nil {
+		progressCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go progress.run(progressCtx)
+	}
+
+	errs := make([]error, len(mirrors))
+	var wg sync.WaitGroup
+	for i, m := range mirrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Run(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", labels[i], err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// progressMerger reports the progress of several mirrors at once, with the
+// partitions of every mirror under a name telling them apart.
+type progressMerger struct {
+	report   func([]PartitionProgress)
+	interval time.Duration
+
+	mu        sync.Mutex
+	snapshots [][]PartitionProgress
+}
+
+// collect returns the progress callback of mirror i, reporting its topics
+// under the name returned by rename.
+func (p *progressMerger) collect(i int, rename func(string) string) func([]PartitionProgress) {
+	return func(snapshot []PartitionProgress) {
+		renamed := slices.Clone(snapshot)
+		for j := range renamed {
+			renamed[j].Topic = rename(renamed[j].Topic)
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.snapshots[i] = renamed
+	}
+}
+
+// run reports the merged progress every interval until ctx is done.
+func (p *progressMerger) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			merged := slices.Concat(p.snapshots...)
+			p.mu.Unlock()
+
+			slices.SortFunc(merged, func(a, b PartitionProgress) int {
+				return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+			})
+			if len(merged) > 0 {
+				p.report(merged)
+			}
+		}
+	}
+}
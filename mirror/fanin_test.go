@@ -0,0 +1,190 @@
+package mirror
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestResolveCollisions(t *testing.T) {
+	sources := []Source{
+		{Name: "eu", Topics: []Topic{{Name: "orders"}, {Name: "users"}}},
+		{Name: "us", Topics: []Topic{{Name: "orders"}, {Name: "payments"}}},
+	}
+
+	tests := []struct {
+		name       string
+		sources    []Source
+		collisions string
+		want       [][]string
+		wantErr    bool
+	}{
+		{
+			name:    "distinct topics",
+			sources: []Source{sources[0], {Name: "us", Topics: []Topic{{Name: "payments"}}}},
+			want:    [][]string{{"orders", "users"}, {"payments"}},
+		},
+		{name: "fail", sources: sources, collisions: "fail", wantErr: true},
+		{name: "fail by default", sources: sources, wantErr: true},
+		{
+			name:       "prefix",
+			sources:    sources,
+			collisions: "prefix",
+			want:       [][]string{{"eu.orders", "users"}, {"us.orders", "payments"}},
+		},
+		{
+			name: "prefixed topic collides",
+			sources: []Source{
+				{Name: "eu", Topics: []Topic{{Name: "orders"}}},
+				{Name: "us", Topics: []Topic{{Name: "orders"}, {Name: "eu.orders"}}},
+			},
+			collisions: "prefix",
+			wantErr:    true,
+		},
+		{name: "unknown", sources: sources, collisions: "rename", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topics, err := resolveCollisions(tt.sources, tt.collisions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCollisions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := make([][]string, 0, len(topics))
+			for _, sourceTopics := range topics {
+				var names []string
+				for _, topic := range sourceTopics {
+					names = append(names, topic.SinkName())
+				}
+				got = append(got, names)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("resolveCollisions() sink topics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFanIn_Invalid(t *testing.T) {
+	sources := []Source{
+		{Name: "eu", Topics: []Topic{{Name: "orders"}}},
+		{Name: "us", Topics: []Topic{{Name: "payments"}}},
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		sources []Source
+	}{
+		{name: "no sources"},
+		{name: "unnamed source", sources: []Source{{Topics: []Topic{{Name: "orders"}}}}},
+		{name: "duplicate source", sources: []Source{sources[0], {Name: "eu", Topics: []Topic{{Name: "payments"}}}}},
+		{name: "no topics", sources: []Source{sources[0], {Name: "us"}}},
+		{name: "collision", sources: []Source{sources[0], {Name: "us", Topics: []Topic{{Name: "orders"}}}}},
+		{name: "metrics", opts: Options{MetricsAddr: ":9090"}, sources: sources},
+		{name: "offset map", opts: Options{OffsetMapFile: "offsets.json"}, sources: sources},
+		{name: "groups", opts: Options{Groups: []Group{{Source: "orders-service"}}}, sources: sources},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFanIn(tt.opts, tt.sources, "")
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Errorf("NewFanIn() error = %v, want a *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestNewFanIn_PerTopicOptions(t *testing.T) {
+	sources := []Source{
+		{Name: "eu", Topics: []Topic{{Name: "orders"}}},
+		{Name: "us", Topics: []Topic{{Name: "payments"}}},
+	}
+
+	opts := Options{
+		SinkPartitions: map[string]int32{"orders": 2},
+		Latest:         LatestOptions{Topics: []string{"payments"}},
+	}
+	f, err := NewFanIn(opts, sources, "")
+	if err != nil {
+		t.Fatalf("NewFanIn() error = %v", err)
+	}
+
+	eu, us := f.mirrors[0].opts, f.mirrors[1].opts
+	if len(eu.SinkPartitions) != 1 || us.SinkPartitions != nil {
+		t.Errorf("sink partitions = %v and %v, want only orders on eu", eu.SinkPartitions, us.SinkPartitions)
+	}
+	if len(eu.Latest.Topics) != 0 || !slices.Equal(us.Latest.Topics, []string{"payments"}) {
+		t.Errorf("latest topics = %v and %v, want only payments on us", eu.Latest.Topics, us.Latest.Topics)
+	}
+	if eu.Provenance != "eu" || us.Provenance != "us" {
+		t.Errorf("provenance = %q and %q, want eu and us", eu.Provenance, us.Provenance)
+	}
+}
+
+func TestFanIn(t *testing.T) {
+	skipShort(t)
+
+	eu := newFakeCluster(t, 1, "orders", "users")
+	us := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+
+	produceValues(t, eu, "orders", "eu-order")
+	produceValues(t, eu, "users", "eu-user")
+	produceValues(t, us, "orders", "us-order")
+
+	euTopics, err := ParseTopics([]string{"orders@-2", "users@-2"})
+	if err != nil {
+		t.Fatalf("ParseTopics() error = %v", err)
+	}
+	usTopics, err := ParseTopics([]string{"orders@-2"})
+	if err != nil {
+		t.Fatalf("ParseTopics() error = %v", err)
+	}
+
+	opts := Options{
+		Sink:           []kgo.Opt{kgo.SeedBrokers(sink...)},
+		Timeout:        5 * time.Second,
+		DeleteExisting: true,
+	}
+	f, err := NewFanIn(opts, []Source{
+		{Name: "eu", Client: []kgo.Opt{kgo.SeedBrokers(eu...)}, Topics: euTopics},
+		{Name: "us", Client: []kgo.Opt{kgo.SeedBrokers(us...)}, Topics: usTopics},
+	}, "prefix")
+	if err != nil {
+		t.Fatalf("NewFanIn() error = %v", err)
+	}
+
+	stop := startMirror(t, f)
+	got := consumeRecords(t, sink, 3, "eu.orders", "us.orders", "users")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := map[string]string{
+		"eu.orders": "eu-order",
+		"us.orders": "us-order",
+		"users":     "eu-user",
+	}
+	for _, r := range got {
+		if string(r.Value) != want[r.Topic] {
+			t.Errorf("record of %s = %q, want %q", r.Topic, r.Value, want[r.Topic])
+		}
+
+		var provenance string
+		for _, h := range r.Headers {
+			if h.Key == ProvenanceHeader {
+				provenance = string(h.Value)
+			}
+		}
+		if wantProvenance := want[r.Topic][:2]; provenance != wantProvenance {
+			t.Errorf("provenance of %s = %q, want %q", r.Topic, provenance, wantProvenance)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// Group is a source consumer group whose committed offsets are mirrored to a
+// sink consumer group.
+type Group struct {
+	Source string
+	// Sink is the group committed on the sink, the source group if empty.
+	Sink string
+}
+
+// ParseGroup parses a group argument: group to commit the offsets of the
+// source group to the same group on the sink, or source:sink to rename it.
+func ParseGroup(value string) (Group, error) {
+	source, sink, _ := strings.Cut(value, ":")
+	if source == "" {
+		return Group{}, fmt.Errorf("expected group or source-group:sink-group, got %q", value)
+	}
+	return Group{Source: source, Sink: sink}, nil
+}
+
+// SinkGroup returns the name of the group on the sink.
+func (g Group) SinkGroup() string {
+	if g.Sink == "" {
+		return g.Source
+	}
+	return g.Sink
+}
+
+// groupSyncer commits the offsets of source consumer groups, translated to
+// sink offsets, on the sink. A nil *groupSyncer is valid and syncs nothing.
+type groupSyncer struct {
+	groups  []Group
+	source  *kadm.Client
+	sink    *kadm.Client
+	offsets *OffsetMap
+	// sinkTopic returns the name of the sink topic of a source topic.
+	sinkTopic func(string) string
+	timeout   time.Duration
+	logger    *slog.Logger
+
+	mu sync.Mutex
+	// committed holds the sink offsets last committed per sink group, so
+	// unchanged offsets aren't committed again.
+	committed map[string]kadm.Offsets
+}
+
+func newGroupSyncer(groups []Group, source, sink *kadm.Client, offsets *OffsetMap, sinkTopic func(string) string, timeout time.Duration) *groupSyncer {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	return &groupSyncer{
+		groups:    groups,
+		source:    source,
+		sink:      sink,
+		offsets:   offsets,
+		sinkTopic: sinkTopic,
+		timeout:   timeout,
+		logger:    sideLogger(sideSink),
+		committed: map[string]kadm.Offsets{},
+	}
+}
+
+// run syncs the groups every interval until ctx is done.
+func (s *groupSyncer) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync(ctx)
+		}
+	}
+}
+
+// sync commits the translated offsets of every group. Failures are logged
+// rather than returned, so a group that can't be synced, e.g. because it has
+// active members on the sink, doesn't stop the mirror.
+func (s *groupSyncer) sync(ctx context.Context) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, group := range s.groups {
+		if err := s.syncGroup(ctx, group); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to sync consumer group offsets",
+				slog.String("group", group.Source),
+				slog.String("sink_group", group.SinkGroup()),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+func (s *groupSyncer) syncGroup(rootCtx context.Context, group Group) error {
+	ctx, cancel := context.WithTimeout(rootCtx, s.timeout)
+	defer cancel()
+
+	fetched, err := s.source.FetchOffsets(ctx, group.Source)
+	if err != nil {
+		return fmt.Errorf("failed to fetch source offsets: %w", err)
+	}
+	if err := fetched.Error(); err != nil {
+		return fmt.Errorf("failed to fetch source offsets: %w", err)
+	}
+
+	sinkGroup := group.SinkGroup()
+	previous := s.committed[sinkGroup]
+
+	translated := kadm.Offsets{}
+	fetched.Each(func(o kadm.OffsetResponse) {
+		if o.At < 0 {
+			return
+		}
+
+		sinkOffset, _, ok := s.offsets.Translate(o.Topic, o.Partition, o.At)
+		if !ok {
+			return
+		}
+
+		topic := s.sinkTopic(o.Topic)
+		if last, ok := previous.Lookup(topic, o.Partition); ok && last.At == sinkOffset {
+			return
+		}
+		translated.Add(kadm.Offset{
+			Topic:       topic,
+			Partition:   o.Partition,
+			At:          sinkOffset,
+			LeaderEpoch: -1,
+			Metadata:    o.Metadata,
+		})
+	})
+	if len(translated) == 0 {
+		return nil
+	}
+
+	committed, err := s.sink.CommitOffsets(ctx, sinkGroup, translated)
+	if err != nil {
+		return fmt.Errorf("failed to commit sink offsets: %w", err)
+	}
+
+	if previous == nil {
+		previous = kadm.Offsets{}
+	}
+	committed.Each(func(o kadm.OffsetResponse) {
+		if o.Err == nil {
+			previous.Add(o.Offset)
+		}
+	})
+	s.committed[sinkGroup] = previous
+	if err := committed.Error(); err != nil {
+		return fmt.Errorf("failed to commit sink offsets: %w", err)
+	}
+
+	s.logger.Info("Synced consumer group offsets",
+		slog.String("group", group.Source),
+		slog.String("sink_group", sinkGroup),
+		slog.Int("partitions", len(translated.Sorted())),
+	)
+	return nil
+}
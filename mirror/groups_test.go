@@ -0,0 +1,44 @@
+package mirror
+
+import "testing"
+
+func TestParseGroup(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Group
+		sink    string
+		wantErr bool
+	}{
+		{value: "orders-service", want: Group{Source: "orders-service"}, sink: "orders-service"},
+		{value: "orders-service:orders-local", want: Group{Source: "orders-service", Sink: "orders-local"}, sink: "orders-local"},
+		{value: "", wantErr: true},
+		{value: ":orders-local", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseGroup(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseGroup() = %+v, want %+v", got, tt.want)
+			}
+			if got.SinkGroup() != tt.sink {
+				t.Errorf("SinkGroup() = %q, want %q", got.SinkGroup(), tt.sink)
+			}
+		})
+	}
+}
+
+func TestGroupSyncer_Nil(t *testing.T) {
+	if s := newGroupSyncer(nil, nil, nil, nil, nil, 0); s != nil {
+		t.Fatalf("newGroupSyncer() without groups = %v, want nil", s)
+	}
+
+	var s *groupSyncer
+	s.sync(t.Context())
+}
@@ -0,0 +1,89 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// clusterIdentity identifies a Kafka cluster by its ID and broker addresses.
+type clusterIdentity struct {
+	ID    string
+	Hosts []string
+}
+
+func (ci clusterIdentity) String() string {
+	if ci.ID == "" {
+		return strings.Join(ci.Hosts, ",")
+	}
+	return ci.ID
+}
+
+func (m *Mirror) getClusterIdentity(rootCtx context.Context, client *kadm.Client) (clusterIdentity, error) {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	metadata, err := client.BrokerMetadata(ctx)
+	if err != nil {
+		return clusterIdentity{}, fmt.Errorf("failed to get cluster metadata: %w", err)
+	}
+
+	out := clusterIdentity{ID: metadata.Cluster}
+	for _, broker := range metadata.Brokers {
+		out.Hosts = append(out.Hosts, net.JoinHostPort(broker.Host, strconv.Itoa(int(broker.Port))))
+	}
+	slices.Sort(out.Hosts)
+
+	return out, nil
+}
+
+// checkDistinctClusters refuses to mirror a cluster into itself, which would
+// delete the very topics that are about to be mirrored.
+func checkDistinctClusters(source, sink clusterIdentity) error {
+	if source.ID != "" || sink.ID != "" {
+		if source.ID == sink.ID {
+			return fmt.Errorf("source and sink are the same cluster %q", sink.ID)
+		}
+		return nil
+	}
+
+	// Without cluster IDs, sharing any broker means sharing the cluster.
+	for _, host := range sink.Hosts {
+		if slices.Contains(source.Hosts, host) {
+			return fmt.Errorf("source and sink share broker %q", host)
+		}
+	}
+	return nil
+}
+
+// checkSinkAllowed accepts the sink if the allow-list is empty, contains its
+// cluster ID, or contains every one of its brokers by host or host:port.
+func checkSinkAllowed(sink clusterIdentity, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	if sink.ID != "" && slices.Contains(allowed, sink.ID) {
+		return nil
+	}
+
+	for _, hostPort := range sink.Hosts {
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			host = hostPort
+		}
+		if !slices.Contains(allowed, hostPort) && !slices.Contains(allowed, host) {
+			return fmt.Errorf("sink broker %q of cluster %q is not in the allow-list", hostPort, sink)
+		}
+	}
+
+	if len(sink.Hosts) == 0 {
+		return fmt.Errorf("sink cluster %q is not in the allow-list", sink)
+	}
+	return nil
+}
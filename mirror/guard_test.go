@@ -0,0 +1,70 @@
+package mirror
+
+import "testing"
+
+func TestCheckDistinctClusters(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  clusterIdentity
+		sink    clusterIdentity
+		wantErr bool
+	}{
+		{
+			name:   "different cluster IDs",
+			source: clusterIdentity{ID: "prod", Hosts: []string{"kafka:9092"}},
+			sink:   clusterIdentity{ID: "local", Hosts: []string{"kafka:9092"}},
+		},
+		{
+			name:    "same cluster ID",
+			source:  clusterIdentity{ID: "prod", Hosts: []string{"a:9092"}},
+			sink:    clusterIdentity{ID: "prod", Hosts: []string{"b:9092"}},
+			wantErr: true,
+		},
+		{
+			name:   "no cluster IDs and distinct brokers",
+			source: clusterIdentity{Hosts: []string{"a:9092"}},
+			sink:   clusterIdentity{Hosts: []string{"b:9092"}},
+		},
+		{
+			name:    "no cluster IDs and shared broker",
+			source:  clusterIdentity{Hosts: []string{"a:9092", "b:9092"}},
+			sink:    clusterIdentity{Hosts: []string{"b:9092"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDistinctClusters(tt.source, tt.sink)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkDistinctClusters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSinkAllowed(t *testing.T) {
+	sink := clusterIdentity{ID: "local-id", Hosts: []string{"localhost:9092", "localhost:9093"}}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "empty allow-list", allowed: nil},
+		{name: "cluster ID", allowed: []string{"local-id"}},
+		{name: "host", allowed: []string{"localhost"}},
+		{name: "host and port", allowed: []string{"localhost:9092", "localhost:9093"}},
+		{name: "one port missing", allowed: []string{"localhost:9092"}, wantErr: true},
+		{name: "other cluster", allowed: []string{"prod-id", "kafka.prod"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSinkAllowed(sink, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSinkAllowed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
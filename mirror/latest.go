@@ -0,0 +1,380 @@
+package mirror
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// LatestOptions defines the topics mirrored with only the latest record per
+// key. They are read up to their high watermark at startup, and the latest
+// record of every key is produced once it is reached.
+type LatestOptions struct {
+	Topics []string
+	// Store is where the latest record per key is kept until it is produced,
+	// memory (the default) or disk.
+	Store string
+	// StoreDir is the directory of the disk store, the system temporary
+	// directory if empty.
+	StoreDir string
+	// Follow keeps mirroring new records of the topics once their latest
+	// records are produced.
+	Follow bool
+}
+
+// keyStore keeps the latest record of every key of a topic.
+type keyStore interface {
+	// Put stores r as the latest record of its key.
+	Put(r *kgo.Record) error
+	// Delete forgets the record of key.
+	Delete(key []byte) error
+	// Each calls fn with every stored record, in source partition and
+	// offset order.
+	Each(fn func(*kgo.Record) error) error
+	// Len returns the number of stored keys.
+	Len() int
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// storeKey returns the map key of a record. Records without a key can't be
+// deduplicated, so each of them is kept under a key of its own.
+func storeKey(r *kgo.Record) string {
+	if r.Key == nil {
+		return "n" + strconv.Itoa(int(r.Partition)) + "/" + strconv.FormatInt(r.Offset, 10)
+	}
+	return "k" + string(r.Key)
+}
+
+func compareSourcePosition(aPartition int32, aOffset int64, bPartition int32, bOffset int64) int {
+	return cmp.Or(cmp.Compare(aPartition, bPartition), cmp.Compare(aOffset, bOffset))
+}
+
+// memoryStore is a keyStore holding the records in memory.
+type memoryStore struct {
+	records map[string]*kgo.Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*kgo.Record{}}
+}
+
+func (s *memoryStore) Put(r *kgo.Record) error {
+	s.records[storeKey(r)] = r
+	return nil
+}
+
+func (s *memoryStore) Delete(key []byte) error {
+	delete(s.records, "k"+string(key))
+	return nil
+}
+
+func (s *memoryStore) Each(fn func(*kgo.Record) error) error {
+	records := make([]*kgo.Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	slices.SortFunc(records, func(a, b *kgo.Record) int {
+		return compareSourcePosition(a.Partition, a.Offset, b.Partition, b.Offset)
+	})
+
+	for _, r := range records {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Len() int { return len(s.records) }
+
+func (s *memoryStore) Close() error {
+	s.records = nil
+	return nil
+}
+
+// diskRef locates a record in the file of a diskStore.
+type diskRef struct {
+	pos       int64
+	size      int
+	partition int32
+	offset    int64
+}
+
+// diskStore is a keyStore appending records to a temporary file and keeping
+// only their location in memory, for topics whose values don't fit in
+// memory.
+type diskStore struct {
+	file   *os.File
+	writer *bufio.Writer
+	pos    int64
+	refs   map[string]diskRef
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	file, err := os.CreateTemp(dir, "kmir-latest-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store file: %w", err)
+	}
+
+	return &diskStore{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		refs:   map[string]diskRef{},
+	}, nil
+}
+
+func (s *diskStore) Put(r *kgo.Record) error {
+	data, err := json.Marshal(NewCapturedRecord(r))
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	if _, err := s.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	s.refs[storeKey(r)] = diskRef{pos: s.pos, size: len(data), partition: r.Partition, offset: r.Offset}
+	s.pos += int64(len(data))
+	return nil
+}
+
+func (s *diskStore) Delete(key []byte) error {
+	delete(s.refs, "k"+string(key))
+	return nil
+}
+
+func (s *diskStore) Each(fn func(*kgo.Record) error) error {
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush store file: %w", err)
+	}
+
+	refs := make([]diskRef, 0, len(s.refs))
+	for _, ref := range s.refs {
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, func(a, b diskRef) int {
+		return compareSourcePosition(a.partition, a.offset, b.partition, b.offset)
+	})
+
+	var buf []byte
+	for _, ref := range refs {
+		buf = slices.Grow(buf[:0], ref.size)[:ref.size]
+		if _, err := s.file.ReadAt(buf, ref.pos); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read record: %w", err)
+		}
+
+		var cr CapturedRecord
+		if err := json.Unmarshal(buf, &cr); err != nil {
+			return fmt.Errorf("failed to decode record: %w", err)
+		}
+
+		r := cr.Record()
+		r.Offset = cr.Offset
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *diskStore) Len() int { return len(s.refs) }
+
+func (s *diskStore) Close() error {
+	closeErr := s.file.Close()
+	if err := os.Remove(s.file.Name()); err != nil {
+		return fmt.Errorf("failed to remove store file: %w", err)
+	}
+	return closeErr
+}
+
+// compactedTopic is a topic mirrored with only the latest record per key.
+type compactedTopic struct {
+	store keyStore
+	// ends is the planned end of every partition when the mirror started.
+	ends map[int32]int64
+	// remaining holds the partitions that haven't reached their end yet.
+	remaining map[int32]bool
+	// pending holds records past the end, received before the snapshot was
+	// complete, to be produced right after it.
+	pending []*kgo.Record
+	flushed bool
+}
+
+// compactor reads the selected topics up to their planned end, keeps the
+// latest record per key and produces them once the snapshot is complete. A
+// nil *compactor is valid and compacts nothing.
+type compactor struct {
+	topics map[string]*compactedTopic
+	follow bool
+}
+
+func newCompactor(topics []string, plan mirrorPlan, follow bool, newStore func() (keyStore, error)) (*compactor, error) {
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	c := &compactor{topics: map[string]*compactedTopic{}, follow: follow}
+	for _, pt := range plan.Create {
+		if !slices.Contains(topics, pt.Topic) {
+			continue
+		}
+
+		store, err := newStore()
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+
+		ct := &compactedTopic{store: store, ends: map[int32]int64{}, remaining: map[int32]bool{}}
+		for _, p := range pt.Offsets {
+			ct.ends[p.Partition] = p.HighWatermark
+			if p.StartOffset < p.HighWatermark {
+				ct.remaining[p.Partition] = true
+			}
+		}
+		c.topics[pt.Topic] = ct
+	}
+
+	for _, topic := range topics {
+		if _, ok := c.topics[topic]; !ok {
+			_ = c.Close()
+			return nil, fmt.Errorf("topic %q to keep the latest record per key of is not mirrored", topic)
+		}
+	}
+
+	return c, nil
+}
+
+// absorb takes r over if it belongs to a compacted topic, in which case the
+// caller must not produce it.
+func (c *compactor) absorb(r *kgo.Record) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+
+	ct, ok := c.topics[r.Topic]
+	if !ok {
+		return false, nil
+	}
+
+	if ct.flushed {
+		return !c.follow, nil
+	}
+
+	end := ct.ends[r.Partition]
+	if r.Offset >= end {
+		if c.follow {
+			ct.pending = append(ct.pending, r)
+		}
+		return true, nil
+	}
+
+	var err error
+	if r.Value == nil && r.Key != nil {
+		err = ct.store.Delete(r.Key)
+	} else {
+		err = ct.store.Put(r)
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to store record: %w", err)
+	}
+
+	if r.Offset+1 >= end {
+		delete(ct.remaining, r.Partition)
+	}
+	return true, nil
+}
+
+// skip notes the offset of r, a record that isn't mirrored such as a
+// transaction marker or a sampled out record, so a snapshot ending with it
+// completes.
+func (c *compactor) skip(r *kgo.Record) {
+	if c == nil {
+		return
+	}
+
+	ct, ok := c.topics[r.Topic]
+	if !ok || ct.flushed {
+		return
+	}
+	if r.Offset+1 >= ct.ends[r.Partition] {
+		delete(ct.remaining, r.Partition)
+	}
+}
+
+// ready returns the topics whose snapshot is complete but not produced yet.
+func (c *compactor) ready() []string {
+	if c == nil {
+		return nil
+	}
+
+	var out []string
+	for topic, ct := range c.topics {
+		if !ct.flushed && len(ct.remaining) == 0 {
+			out = append(out, topic)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// flush produces the latest record of every key of topic, followed by the
+// records received past the snapshot, and releases its store.
+func (c *compactor) flush(topic string, produce func(*kgo.Record) error) (int, error) {
+	ct := c.topics[topic]
+	keys := ct.store.Len()
+
+	if err := ct.store.Each(produce); err != nil {
+		return 0, err
+	}
+	for _, r := range ct.pending {
+		if err := produce(r); err != nil {
+			return 0, err
+		}
+	}
+
+	ct.pending = nil
+	ct.flushed = true
+	return keys, ct.store.Close()
+}
+
+// done reports whether every compacted topic has been produced and nothing
+// is left to follow.
+func (c *compactor) done() bool {
+	if c == nil || c.follow {
+		return false
+	}
+
+	for _, ct := range c.topics {
+		if !ct.flushed {
+			return false
+		}
+	}
+	return true
+}
+
+// Close releases the stores of the topics that were not flushed.
+func (c *compactor) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	var firstErr error
+	for _, ct := range c.topics {
+		if ct.flushed {
+			continue
+		}
+		if err := ct.store.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,300 @@
+package mirror
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKeyStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) keyStore{
+		"memory": func(*testing.T) keyStore { return newMemoryStore() },
+		"disk": func(t *testing.T) keyStore {
+			store, err := newDiskStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("newDiskStore() error = %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			defer func() { _ = store.Close() }()
+
+			for _, r := range []*kgo.Record{
+				{Partition: 1, Offset: 0, Key: []byte("a"), Value: []byte("a1")},
+				{Partition: 0, Offset: 0, Key: []byte("b"), Value: []byte("b1")},
+				{Partition: 0, Offset: 1, Value: []byte("no key")},
+				{Partition: 1, Offset: 1, Key: []byte("a"), Value: []byte("a2")},
+				{Partition: 0, Offset: 2, Key: []byte("c"), Value: []byte("c1")},
+			} {
+				if err := store.Put(r); err != nil {
+					t.Fatalf("Put() error = %v", err)
+				}
+			}
+			if err := store.Delete([]byte("c")); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if store.Len() != 3 {
+				t.Errorf("Len() = %d, want 3", store.Len())
+			}
+
+			var values []string
+			err := store.Each(func(r *kgo.Record) error {
+				values = append(values, string(r.Value))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Each() error = %v", err)
+			}
+
+			want := []string{"b1", "no key", "a2"}
+			if !slices.Equal(values, want) {
+				t.Errorf("Each() values = %v, want %v", values, want)
+			}
+		})
+	}
+}
+
+func TestDiskStore_CloseRemovesFile(t *testing.T) {
+	store, err := newDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newDiskStore() error = %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(store.file.Name()); !os.IsNotExist(err) {
+		t.Errorf("store file still exists after Close(), stat error = %v", err)
+	}
+}
+
+func TestCompactor(t *testing.T) {
+	plan := mirrorPlan{Create: []plannedTopic{
+		{Topic: "state", Offsets: []plannedPartition{
+			{Partition: 0, StartOffset: 0, HighWatermark: 4},
+			{Partition: 1, StartOffset: 0, HighWatermark: 0},
+		}},
+		{Topic: "events"},
+	}}
+
+	tests := []struct {
+		follow      bool
+		wantFlushed []string
+		wantAfter   bool
+		wantDone    bool
+	}{
+		{follow: false, wantFlushed: []string{"v2"}, wantAfter: true, wantDone: true},
+		{follow: true, wantFlushed: []string{"v2", "new"}, wantAfter: false, wantDone: false},
+	}
+
+	for _, tt := range tests {
+		c, err := newCompactor([]string{"state"}, plan, tt.follow, func() (keyStore, error) { return newMemoryStore(), nil })
+		if err != nil {
+			t.Fatalf("newCompactor() error = %v", err)
+		}
+
+		for _, r := range []*kgo.Record{
+			{Topic: "events", Partition: 0, Offset: 0, Key: []byte("k")},
+			{Topic: "state", Partition: 0, Offset: 0, Key: []byte("k"), Value: []byte("v1")},
+			{Topic: "state", Partition: 0, Offset: 1, Key: []byte("gone"), Value: []byte("x")},
+			{Topic: "state", Partition: 0, Offset: 2, Key: []byte("k"), Value: []byte("v2")},
+			{Topic: "state", Partition: 0, Offset: 3, Key: []byte("gone")},
+		} {
+			if len(c.ready()) != 0 {
+				t.Fatalf("ready() = %v before the high watermark is reached", c.ready())
+			}
+
+			absorbed, err := c.absorb(r)
+			if err != nil {
+				t.Fatalf("absorb() error = %v", err)
+			}
+			if absorbed != (r.Topic == "state") {
+				t.Errorf("absorb(%s) = %v", r.Topic, absorbed)
+			}
+		}
+
+		// Records past the high watermark before the snapshot is produced.
+		absorbed, _ := c.absorb(&kgo.Record{Topic: "state", Partition: 1, Offset: 0, Key: []byte("k"), Value: []byte("new")})
+		if !absorbed {
+			t.Errorf("absorb() of a record past the high watermark = false, want true")
+		}
+
+		if got := c.ready(); !slices.Equal(got, []string{"state"}) {
+			t.Fatalf("ready() = %v, want [state]", got)
+		}
+
+		var flushed []string
+		keys, err := c.flush("state", func(r *kgo.Record) error {
+			flushed = append(flushed, string(r.Value))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("flush() error = %v", err)
+		}
+		if keys != 1 {
+			t.Errorf("flush() keys = %d, want 1", keys)
+		}
+		if !slices.Equal(flushed, tt.wantFlushed) {
+			t.Errorf("flush() produced %v, want %v", flushed, tt.wantFlushed)
+		}
+
+		absorbed, _ = c.absorb(&kgo.Record{Topic: "state", Partition: 0, Offset: 4, Key: []byte("k")})
+		if absorbed != tt.wantAfter {
+			t.Errorf("absorb() after flush = %v, want %v", absorbed, tt.wantAfter)
+		}
+		if c.done() != tt.wantDone {
+			t.Errorf("done() = %v, want %v", c.done(), tt.wantDone)
+		}
+	}
+}
+
+func TestNewCompactor_UnknownTopic(t *testing.T) {
+	plan := mirrorPlan{Create: []plannedTopic{{Topic: "events"}}}
+	_, err := newCompactor([]string{"state"}, plan, false, func() (keyStore, error) { return newMemoryStore(), nil })
+	if err == nil {
+		t.Errorf("newCompactor() error = nil, want error for a topic that is not mirrored")
+	}
+}
+
+func TestCompactor_Skip(t *testing.T) {
+	plan := mirrorPlan{Create: []plannedTopic{
+		{Topic: "state", Offsets: []plannedPartition{{Partition: 0, StartOffset: 0, HighWatermark: 2}}},
+	}}
+
+	c, err := newCompactor([]string{"state"}, plan, false, func() (keyStore, error) { return newMemoryStore(), nil })
+	if err != nil {
+		t.Fatalf("newCompactor() error = %v", err)
+	}
+
+	if _, err := c.absorb(&kgo.Record{Topic: "state", Partition: 0, Offset: 0, Key: []byte("k"), Value: []byte("v")}); err != nil {
+		t.Fatalf("absorb() error = %v", err)
+	}
+	if got := c.ready(); len(got) != 0 {
+		t.Fatalf("ready() = %v before the high watermark is reached", got)
+	}
+
+	// The high watermark is reached with a transaction marker.
+	c.skip(&kgo.Record{Topic: "state", Partition: 0, Offset: 1})
+	if got := c.ready(); !slices.Equal(got, []string{"state"}) {
+		t.Errorf("ready() = %v, want [state]", got)
+	}
+
+	var nilCompactor *compactor
+	nilCompactor.skip(&kgo.Record{Topic: "state"})
+}
+
+func TestMirror_LatestSkippedLastRecord(t *testing.T) {
+	skipShort(t)
+
+	tests := []struct {
+		name      string
+		configure func(*Options)
+	}{
+		{
+			// nth=2 keeps a and c, the last record is sampled out.
+			name:      "sampled out",
+			configure: func(opts *Options) { opts.Sample = map[string]string{"state": "nth=2"} },
+		},
+		{
+			// The last record came from the sink.
+			name:      "excluded origin",
+			configure: func(opts *Options) { opts.ExcludeOrigin = "dev" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeCluster(t, 1, "state")
+			sink := newFakeCluster(t, 1)
+
+			client, err := kgo.NewClient(kgo.SeedBrokers(source...))
+			if err != nil {
+				t.Fatalf("kgo.NewClient() error = %v", err)
+			}
+			defer client.Close()
+
+			var records []*kgo.Record
+			for _, key := range []string{"a", "b", "c"} {
+				records = append(records, &kgo.Record{Topic: "state", Key: []byte(key), Value: []byte(key)})
+			}
+			records = append(records, &kgo.Record{
+				Topic:   "state",
+				Key:     []byte("d"),
+				Value:   []byte("d"),
+				Headers: []kgo.RecordHeader{{Key: ProvenanceHeader, Value: []byte("dev")}},
+			})
+			if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+				t.Fatalf("ProduceSync() error = %v", err)
+			}
+
+			opts := testOptions(t, source, sink, "state@-2")
+			opts.Latest = LatestOptions{Topics: []string{"state"}}
+			tt.configure(&opts)
+
+			// The mirror stops once the latest records are produced, though
+			// the last record before the high watermark isn't mirrored.
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := newTestMirror(t, opts).Run(ctx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("Run() stopped with the context, the latest records were never produced")
+			}
+
+			if got := recordValues(consumeRecords(t, sink, 2, "state")); !slices.Contains(got, "a") || slices.Contains(got, "d") {
+				t.Errorf("mirrored records = %v, want a and no d", got)
+			}
+		})
+	}
+}
+
+func TestMirror_LatestOpenTransaction(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "state")
+	sink := newFakeCluster(t, 1)
+	produceTransaction(t, source, "committed", "state", true, "a", "b")
+
+	// A transaction still open at startup holds back the last stable offset.
+	producer, err := kgo.NewClient(kgo.SeedBrokers(source...), kgo.TransactionalID("producer"))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer producer.Close()
+
+	if err := producer.BeginTransaction(); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	r := &kgo.Record{Topic: "state", Key: []byte("c"), Value: []byte("c")}
+	if err := producer.ProduceSync(context.Background(), r).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	opts := testOptions(t, source, sink, "state@-2")
+	opts.Latest = LatestOptions{Topics: []string{"state"}}
+
+	// The snapshot ends at the last stable offset, the high watermark is
+	// only reached once the transaction ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := newTestMirror(t, opts).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("Run() stopped with the context, the latest records were never produced")
+	}
+
+	if got := recordValues(consumeRecords(t, sink, 2, "state")); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("mirrored records = %v, want [a b]", got)
+	}
+}
@@ -0,0 +1,41 @@
+package mirror
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Cluster sides, used as the value of the "side" log attribute.
+const (
+	sideSource = "source"
+	sideSink   = "sink"
+)
+
+func sideLogger(side string) *slog.Logger {
+	return slog.Default().With(slog.String("side", side))
+}
+
+// recordAttrs returns the attributes identifying a record on every log line.
+func recordAttrs(r *kgo.Record) []slog.Attr {
+	return []slog.Attr{
+		slog.String("topic", r.Topic),
+		slog.Int("partition", int(r.Partition)),
+		slog.Int64("offset", r.Offset),
+	}
+}
+
+// logRecord logs r at debug level for the given fraction of records.
+func logRecord(ctx context.Context, logger *slog.Logger, sampling float64, msg string, r *kgo.Record) {
+	if sampling <= 0 || !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	if sampling < 1 && rand.Float64() >= sampling { // #nosec G404
+		return
+	}
+
+	attrs := append(recordAttrs(r), slog.Int("key_bytes", len(r.Key)), slog.Int("value_bytes", len(r.Value)))
+	logger.LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
@@ -0,0 +1,36 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestLogRecord(t *testing.T) {
+	r := &kgo.Record{Topic: "orders", Partition: 2, Offset: 7, Value: []byte("value")}
+
+	tests := []struct {
+		name     string
+		sampling float64
+		want     bool
+	}{
+		{"disabled", 0, false},
+		{"every record", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			logRecord(context.Background(), logger, tt.sampling, "Consumed record", r)
+
+			if got := strings.Contains(buf.String(), "topic=orders partition=2 offset=7"); got != tt.want {
+				t.Errorf("logRecord() output = %q, want logged %v", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package mirror
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/plugin/kprom"
+)
+
+const metricsNamespace = "kmir"
+
+// mirrorMetrics holds the Prometheus metrics of the mirror loop. A nil
+// *mirrorMetrics is valid and records nothing, so callers don't have to
+// check whether metrics are enabled.
+type mirrorMetrics struct {
+	registry *prometheus.Registry
+
+	recordsConsumed *prometheus.CounterVec
+	bytesConsumed   *prometheus.CounterVec
+	recordsProduced *prometheus.CounterVec
+	bytesProduced   *prometheus.CounterVec
+	produceErrors   *prometheus.CounterVec
+	sampledRecords  *prometheus.CounterVec
+	lag             *prometheus.GaugeVec
+	fetchLatency    prometheus.Histogram
+	produceLatency  *prometheus.HistogramVec
+}
+
+func newMirrorMetrics() *mirrorMetrics {
+	partitionLabels := []string{"topic", "partition"}
+	// The sink label is empty for a mirror with a single sink.
+	sinkPartitionLabels := []string{"sink", "topic", "partition"}
+
+	m := &mirrorMetrics{
+		registry: prometheus.NewRegistry(),
+		recordsConsumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "records_consumed_total",
+			Help:      "Number of records consumed from the source.",
+		}, partitionLabels),
+		bytesConsumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "bytes_consumed_total",
+			Help:      "Number of key and value bytes consumed from the source.",
+		}, partitionLabels),
+		recordsProduced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "records_produced_total",
+			Help:      "Number of records successfully produced to the sink.",
+		}, sinkPartitionLabels),
+		bytesProduced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "bytes_produced_total",
+			Help:      "Number of key and value bytes successfully produced to the sink.",
+		}, sinkPartitionLabels),
+		produceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "produce_errors_total",
+			Help:      "Number of records that failed to be produced to the sink.",
+		}, sinkPartitionLabels),
+		sampledRecords: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "sampled_records_total",
+			Help:      "Number of records kept or dropped by sampling.",
+		}, []string{"topic", "result"}),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "lag_records",
+			Help:      "Number of records between the last consumed offset and the source high watermark.",
+		}, partitionLabels),
+		fetchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "Time spent polling the source for records.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		produceLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "produce_latency_seconds",
+			Help:      "Time between producing a record and its acknowledgement by the sink.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"sink", "topic"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.recordsConsumed,
+		m.bytesConsumed,
+		m.recordsProduced,
+		m.bytesProduced,
+		m.produceErrors,
+		m.sampledRecords,
+		m.lag,
+		m.fetchLatency,
+		m.produceLatency,
+	)
+
+	return m
+}
+
+// clientOpts returns the options that hook a client of the given side into
+// the franz-go client metrics.
+func (m *mirrorMetrics) clientOpts(side string) []kgo.Opt {
+	if m == nil {
+		return nil
+	}
+
+	hooks := kprom.NewMetrics(metricsNamespace, kprom.Registry(m.registry), kprom.Subsystem(side))
+	return []kgo.Opt{kgo.WithHooks(hooks)}
+}
+
+func (m *mirrorMetrics) observeFetch(started time.Time) {
+	if m == nil {
+		return
+	}
+	m.fetchLatency.Observe(time.Since(started).Seconds())
+}
+
+func (m *mirrorMetrics) observePartition(p kgo.FetchTopicPartition) {
+	if m == nil || len(p.Records) == 0 {
+		return
+	}
+
+	last := p.Records[len(p.Records)-1]
+	m.lag.WithLabelValues(p.Topic, strconv.Itoa(int(p.Partition))).Set(float64(max(p.HighWatermark-last.Offset-1, 0)))
+}
+
+func (m *mirrorMetrics) consumed(r *kgo.Record) {
+	if m == nil {
+		return
+	}
+
+	partition := strconv.Itoa(int(r.Partition))
+	m.recordsConsumed.WithLabelValues(r.Topic, partition).Inc()
+	m.bytesConsumed.WithLabelValues(r.Topic, partition).Add(float64(len(r.Key) + len(r.Value)))
+}
+
+// sampled records whether sampling kept r.
+func (m *mirrorMetrics) sampled(r *kgo.Record, kept bool) {
+	if m == nil {
+		return
+	}
+
+	result := "dropped"
+	if kept {
+		result = "kept"
+	}
+	m.sampledRecords.WithLabelValues(r.Topic, result).Inc()
+}
+
+// produced records the outcome of producing r to sink, which was handed to
+// the sink client at started.
+func (m *mirrorMetrics) produced(sink string, r *kgo.Record, started time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	partition := strconv.Itoa(int(r.Partition))
+	if err != nil {
+		m.produceErrors.WithLabelValues(sink, r.Topic, partition).Inc()
+		return
+	}
+
+	m.recordsProduced.WithLabelValues(sink, r.Topic, partition).Inc()
+	m.bytesProduced.WithLabelValues(sink, r.Topic, partition).Add(float64(len(r.Key) + len(r.Value)))
+	m.produceLatency.WithLabelValues(sink, r.Topic).Observe(time.Since(started).Seconds())
+}
+
+// serve exposes the metrics on addr until the returned server is closed.
+func (m *mirrorMetrics) serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", slog.String("addr", addr), slog.Any("error", err))
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,62 @@
+package mirror
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestMirrorMetrics_Nil(t *testing.T) {
+	var m *mirrorMetrics
+
+	// None of these may panic when metrics are disabled.
+	m.observeFetch(time.Now())
+	m.observePartition(kgo.FetchTopicPartition{})
+	m.consumed(&kgo.Record{})
+	m.produced("", &kgo.Record{}, time.Now(), nil)
+	if opts := m.clientOpts("source"); opts != nil {
+		t.Errorf("clientOpts() = %v, want nil", opts)
+	}
+}
+
+func TestMirrorMetrics_Counters(t *testing.T) {
+	m := newMirrorMetrics()
+	r := &kgo.Record{Topic: "orders", Partition: 1, Key: []byte("key"), Value: []byte("value")}
+
+	m.consumed(r)
+	m.consumed(r)
+	m.produced("", r, time.Now(), nil)
+	m.produced("", r, time.Now(), errors.New("boom"))
+
+	if got := testutil.ToFloat64(m.recordsConsumed.WithLabelValues("orders", "1")); got != 2 {
+		t.Errorf("records consumed = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.bytesConsumed.WithLabelValues("orders", "1")); got != 16 {
+		t.Errorf("bytes consumed = %v, want 16", got)
+	}
+	if got := testutil.ToFloat64(m.recordsProduced.WithLabelValues("", "orders", "1")); got != 1 {
+		t.Errorf("records produced = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.produceErrors.WithLabelValues("", "orders", "1")); got != 1 {
+		t.Errorf("produce errors = %v, want 1", got)
+	}
+}
+
+func TestMirrorMetrics_Lag(t *testing.T) {
+	m := newMirrorMetrics()
+	m.observePartition(kgo.FetchTopicPartition{
+		Topic: "orders",
+		FetchPartition: kgo.FetchPartition{
+			Partition:     0,
+			HighWatermark: 100,
+			Records:       []*kgo.Record{{Offset: 40}, {Offset: 41}},
+		},
+	})
+
+	if got := testutil.ToFloat64(m.lag.WithLabelValues("orders", "0")); got != 58 {
+		t.Errorf("lag = %v, want 58", got)
+	}
+}
@@ -0,0 +1,963 @@
+// Package mirror copies Kafka topics from a source cluster to a sink
+// cluster. It is the engine of the kmir command, usable on its own, e.g. to
+// seed a cluster from tests:
+//
+//	topics, err := mirror.ParseTopics([]string{"orders@-2", "users"})
+//	if err != nil {
+//		return err
+//	}
+//	m, err := mirror.New(mirror.Options{
+//		Source:         []kgo.Opt{kgo.SeedBrokers("prod:9092")},
+//		Sink:           []kgo.Opt{kgo.SeedBrokers("localhost:9092")},
+//		Topics:         topics,
+//		DeleteExisting: true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	return m.Run(ctx)
+//
+// Existing sink topics are deleted and recreated with the partitions of the
+// source topic, so every record keeps its partition. Errors are of the types
+// declared in this package where the cause is known. Logs go to the default
+// slog logger.
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ProvenanceHeader is the header of produced records holding
+// Options.Provenance.
+const ProvenanceHeader = "kmir-source"
+
+const (
+	defaultTimeout           = 10 * time.Second
+	defaultProgressInterval  = time.Second
+	defaultPartitionQueue    = 4
+	defaultGroupSyncInterval = 10 * time.Second
+	defaultOffsetMapInterval = 10 * time.Second
+	defaultCheckpointTopic   = "kmir-checkpoints"
+	defaultCommitInterval    = time.Second
+)
+
+// Options defines what a Mirror copies and how. Source, Sink or Sinks, and
+// Topics are required, the zero value of any other field is a sensible default.
+type Options struct {
+	// Source and Sink are the options of the Kafka clients of each cluster,
+	// at least their seed brokers. The source is read committed unless
+	// Source sets another kgo.FetchIsolationLevel: records of aborted and
+	// open transactions are skipped. Transaction markers are never mirrored.
+	Source []kgo.Opt
+	Sink   []kgo.Opt
+	// Sinks, instead of Sink, are several sink clusters mirrored to at once,
+	// from a single consumer of the source. A sink failing to produce a
+	// record is dropped while the others go on, and Run returns its error
+	// once it stops. OffsetMapFile isn't supported with several sinks.
+	Sinks []Sink
+	// Topics are the topics to mirror, e.g. as returned by ParseTopics.
+	Topics []Topic
+	// Timeout bounds every admin request, and the wait for sink topics to be
+	// deleted and created. It defaults to 10s.
+	Timeout time.Duration
+
+	// DryRun writes the plan to Output instead of mirroring.
+	DryRun bool
+	// PlanFormat is the format of the dry-run plan, table (the default) or
+	// json.
+	PlanFormat string
+	// Output receives the dry-run plan. It defaults to os.Stdout.
+	Output io.Writer
+
+	// DeleteExisting deletes the sink topics that already exist without
+	// asking.
+	DeleteExisting bool
+	// ConfirmDeletion is asked to confirm the deletion of the sink topics that
+	// already exist on the given sink cluster, unless DeleteExisting is set.
+	// If both are unset, the mirror refuses to delete them.
+	ConfirmDeletion func(sink string, topics []string) (bool, error)
+	// KeepSinkTopics produces into the sink topics that already exist
+	// instead of deleting and recreating them; missing ones are created.
+	// Records of a topic with a different number of partitions on the sink
+	// are routed by PartitionRouting. Groups and OffsetMapFile aren't
+	// supported, as they need sink topics starting at offset 0.
+	KeepSinkTopics bool
+	// AllowSink lists the sink broker hosts, host:ports or cluster IDs that
+	// may be written to. If not empty, any other sink is refused.
+	AllowSink []string
+
+	// Progress, if set, is called every ProgressInterval with the progress of
+	// every mirrored partition, sorted by topic and partition.
+	Progress func([]PartitionProgress)
+	// ProgressInterval defaults to a second.
+	ProgressInterval time.Duration
+
+	// MetricsAddr is the address to serve Prometheus metrics on, disabled if
+	// empty.
+	MetricsAddr string
+	// ControlAddr is the address to serve the pause/resume endpoints on,
+	// disabled if empty.
+	ControlAddr string
+	// PauseSignals pauses fetching on SIGUSR1 and resumes it on SIGUSR2.
+	PauseSignals bool
+
+	// PartitionQueue is the number of fetched batches queued per partition
+	// before fetching of the partition is paused. It defaults to 4.
+	PartitionQueue int
+	// PreserveCompression produces records with the compression codec of
+	// their source batch instead of the one of the sink client.
+	PreserveCompression bool
+	// Sample maps a topic, or * for every topic, to the sample of its records
+	// to mirror: nth=N, percent=P or key=P.
+	Sample   map[string]string
+	Throttle ThrottleOptions
+	Latest   LatestOptions
+
+	// ReplicationFactor of the sink topics, the default of the sink brokers
+	// if 0. With SourceReplicationFactor, the replication factor of the
+	// source topic is used instead. Either is clamped to the number of sink
+	// brokers.
+	ReplicationFactor       int16
+	SourceReplicationFactor bool
+	// MinInsyncReplicas of the sink topics. If 0 and the replication factor
+	// is set, the one set on the source topic is used, not a default of the
+	// source brokers. Either is clamped to the replication factor.
+	MinInsyncReplicas int16
+
+	// SinkPartitions overrides the number of partitions of sink topics, by
+	// topic. Records of these topics are routed to sink partitions by
+	// PartitionRouting: modulo (the default) for the source partition modulo
+	// the sink partitions, murmur2 to hash keys like the Kafka partitioner,
+	// or round-robin. Records without a key are routed round-robin by
+	// murmur2 too. Other topics keep the partitions of the source, and
+	// records their source partition.
+	SinkPartitions   map[string]int32
+	PartitionRouting string
+
+	// Groups are the source consumer groups whose committed offsets are
+	// translated to sink offsets and committed on the sink, every
+	// GroupSyncInterval and once the mirror stops.
+	Groups []Group
+	// GroupSyncInterval defaults to 10s.
+	GroupSyncInterval time.Duration
+
+	// ACLs recreates on the sink the source ACLs applying to the mirrored
+	// topics and Groups, whether literal, prefixed or wildcard, with their
+	// principals rewritten by PrincipalRewrites. ACLs of renamed groups
+	// follow the group.
+	ACLs              bool
+	PrincipalRewrites []PrincipalRewrite
+
+	// OffsetMapFile, if set, is the file the source to sink offset map of
+	// the mirrored records is written to, every OffsetMapInterval and once
+	// the mirror stops. It is replaced on every run, as the sink topics are
+	// recreated. See ReadOffsetMap.
+	OffsetMapFile string
+	// OffsetMapInterval defaults to 10s.
+	OffsetMapInterval time.Duration
+
+	// ExactlyOnce produces records in transactions of the sink, so a mirror
+	// restarted with the same transactional ID neither duplicates nor loses
+	// records. See ExactlyOnceOptions.
+	ExactlyOnce ExactlyOnceOptions
+
+	// Provenance, if set, is added to every produced record as the value of
+	// a ProvenanceHeader header, to tell apart records of several sources.
+	Provenance string
+	// ExcludeOrigin, if set, skips the records with a ProvenanceHeader of
+	// ExcludeOrigin, i.e. mirrored from or through that cluster, so records
+	// mirrored from the sink aren't mirrored back to it.
+	ExcludeOrigin string
+
+	// LogRecordSampling is the fraction of mirrored records, from 0 to 1,
+	// logged at debug level.
+	LogRecordSampling float64
+}
+
+// Mirror copies topics from a source cluster to a sink cluster.
+type Mirror struct {
+	opts Options
+	// topics holds the topics to mirror by name, names their names in the
+	// order given and sinkNames the names of their sink topics.
+	topics    map[string]Topic
+	names     []string
+	sinkNames []string
+	// renamed maps the topics mirrored to a sink topic of another name to
+	// that name.
+	renamed map[string]string
+	// sinks holds the mirror of every sink.
+	sinks []sinkMirror
+}
+
+// New returns a Mirror of opts, or a *ConfigError if they are invalid.
+func New(opts Options) (*Mirror, error) {
+	if len(opts.Topics) == 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("no topics specified")}
+	}
+
+	if opts.LogRecordSampling < 0 || opts.LogRecordSampling > 1 {
+		return nil, &ConfigError{Err: fmt.Errorf("log record sampling must be between 0 and 1, got %v", opts.LogRecordSampling)}
+	}
+
+	switch opts.PlanFormat {
+	case "":
+		opts.PlanFormat = "table"
+	case "table", "json":
+	default:
+		return nil, &ConfigError{Err: fmt.Errorf("unknown plan format %q", opts.PlanFormat)}
+	}
+
+	switch opts.Latest.Store {
+	case "", "memory", "disk":
+	default:
+		return nil, &ConfigError{Err: fmt.Errorf("unknown latest-per-key store %q", opts.Latest.Store)}
+	}
+
+	if opts.ReplicationFactor < 0 || opts.MinInsyncReplicas < 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("replication factor and min.insync.replicas can't be negative")}
+	}
+	if opts.ReplicationFactor > 0 && opts.SourceReplicationFactor {
+		return nil, &ConfigError{Err: fmt.Errorf("replication factor %d conflicts with the source replication factor", opts.ReplicationFactor)}
+	}
+
+	switch opts.PartitionRouting {
+	case "":
+		opts.PartitionRouting = "modulo"
+	case "modulo", "murmur2", "round-robin":
+	default:
+		return nil, &ConfigError{Err: fmt.Errorf("unknown partition routing %q", opts.PartitionRouting)}
+	}
+
+	for topic, partitions := range opts.SinkPartitions {
+		if partitions <= 0 {
+			return nil, &ConfigError{Err: fmt.Errorf("sink partitions of topic %q must be positive, got %d", topic, partitions)}
+		}
+		if !slices.Contains(TopicNames(opts.Topics), topic) {
+			return nil, &ConfigError{Err: fmt.Errorf("sink partitions given for topic %q, which isn't mirrored", topic)}
+		}
+	}
+
+	sinkNames := map[string]bool{}
+	for _, topic := range opts.Topics {
+		if sinkNames[topic.SinkName()] {
+			return nil, &ConfigError{Err: fmt.Errorf("several topics are mirrored to sink topic %q", topic.SinkName())}
+		}
+		sinkNames[topic.SinkName()] = true
+	}
+
+	if opts.KeepSinkTopics && (len(opts.Groups) > 0 || opts.OffsetMapFile != "") {
+		return nil, &ConfigError{Err: fmt.Errorf("consumer groups and offset map aren't supported when keeping sink topics")}
+	}
+
+	if err := checkSinks(opts); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	if err := checkExactlyOnce(opts); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	for _, group := range opts.Groups {
+		if group.Source == "" {
+			return nil, &ConfigError{Err: fmt.Errorf("consumer group without a name")}
+		}
+	}
+
+	for _, rewrite := range opts.PrincipalRewrites {
+		if rewrite.From == "" || rewrite.To == "" {
+			return nil, &ConfigError{Err: fmt.Errorf("principal rewrite %q to %q without a principal", rewrite.From, rewrite.To)}
+		}
+	}
+
+	// The throttle and the sampler hold the state of a run, so they are only
+	// validated here and created by Run.
+	if _, err := newThrottle(opts.Throttle); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	if _, err := newSampler(opts.Sample); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = defaultProgressInterval
+	}
+	if opts.PartitionQueue <= 0 {
+		opts.PartitionQueue = defaultPartitionQueue
+	}
+	if opts.GroupSyncInterval <= 0 {
+		opts.GroupSyncInterval = defaultGroupSyncInterval
+	}
+	if opts.OffsetMapInterval <= 0 {
+		opts.OffsetMapInterval = defaultOffsetMapInterval
+	}
+	if opts.ExactlyOnce.CheckpointTopic == "" {
+		opts.ExactlyOnce.CheckpointTopic = defaultCheckpointTopic
+	}
+	if opts.ExactlyOnce.CommitInterval <= 0 {
+		opts.ExactlyOnce.CommitInterval = defaultCommitInterval
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+
+	m := &Mirror{
+		opts:    opts,
+		topics:  make(map[string]Topic, len(opts.Topics)),
+		names:   TopicNames(opts.Topics),
+		renamed: map[string]string{},
+	}
+	for _, topic := range opts.Topics {
+		m.topics[topic.Name] = topic
+		m.sinkNames = append(m.sinkNames, topic.SinkName())
+		if topic.SinkName() != topic.Name {
+			m.renamed[topic.Name] = topic.SinkName()
+		}
+	}
+
+	if len(opts.Sinks) == 0 {
+		m.sinks = []sinkMirror{{mirror: m}}
+		return m, nil
+	}
+
+	for _, sink := range opts.Sinks {
+		sinkOpts := opts
+		sinkOpts.Sink = sink.Client
+		sinkOpts.Sinks = nil
+		if len(sink.Topics) > 0 {
+			sinkOpts.Topics = slices.DeleteFunc(slices.Clone(opts.Topics), func(topic Topic) bool {
+				return !slices.Contains(sink.Topics, topic.Name)
+			})
+			sinkOpts.SinkPartitions = onlyTopics(opts.SinkPartitions, sinkOpts.Topics)
+		}
+
+		sm, err := New(sinkOpts)
+		if err != nil {
+			return nil, err
+		}
+		m.sinks = append(m.sinks, sinkMirror{name: sink.Name, mirror: sm})
+	}
+	return m, nil
+}
+
+// checkSinks validates the sinks of opts.
+func checkSinks(opts Options) error {
+	if len(opts.Sinks) == 0 {
+		return nil
+	}
+	if opts.Sink != nil {
+		return fmt.Errorf("sink and several sinks are given")
+	}
+	if opts.OffsetMapFile != "" {
+		return fmt.Errorf("offset map file isn't supported with several sinks")
+	}
+
+	names := map[string]bool{}
+	for _, sink := range opts.Sinks {
+		if sink.Name == "" {
+			return fmt.Errorf("sink without a name")
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("several sinks are named %q", sink.Name)
+		}
+		names[sink.Name] = true
+
+		for _, topic := range sink.Topics {
+			if !slices.Contains(TopicNames(opts.Topics), topic) {
+				return fmt.Errorf("topic %q of sink %s isn't mirrored", topic, sink.Name)
+			}
+		}
+	}
+
+	for _, topic := range opts.Topics {
+		if !slices.ContainsFunc(opts.Sinks, func(sink Sink) bool {
+			return len(sink.Topics) == 0 || slices.Contains(sink.Topics, topic.Name)
+		}) {
+			return fmt.Errorf("topic %q isn't mirrored to any sink", topic.Name)
+		}
+	}
+	return nil
+}
+
+// sinkTopic returns the name of the sink topic of a mirrored topic.
+func (m *Mirror) sinkTopic(topic string) string {
+	if sink, ok := m.renamed[topic]; ok {
+		return sink
+	}
+	return topic
+}
+
+// newKeyStore returns the store keeping the latest record per key of a
+// topic.
+func (m *Mirror) newKeyStore() (keyStore, error) {
+	if m.opts.Latest.Store == "disk" {
+		store, err := newDiskStore(m.opts.Latest.StoreDir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return newMemoryStore(), nil
+}
+
+// Run mirrors the topics until ctx is done, the latest record per key of
+// every topic is produced, or an error occurs. Stopping through ctx isn't an
+// error.
+func (m *Mirror) Run(rootCtx context.Context) error {
+	opts := &m.opts
+
+	throttle, err := newThrottle(opts.Throttle)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	sampler, err := newSampler(opts.Sample)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	var metrics *mirrorMetrics
+	if opts.MetricsAddr != "" && !opts.DryRun {
+		slog.Info("Serving metrics", slog.String("addr", opts.MetricsAddr))
+		metrics = newMirrorMetrics()
+		server := metrics.serve(opts.MetricsAddr)
+		defer func() { _ = server.Close() }()
+	}
+
+	sourceLog := sideLogger(sideSource)
+
+	sourceLog.Info("Creating Kafka client")
+	// Transaction markers are kept to know the offsets they take, e.g. the
+	// last one of a partition, but aren't mirrored.
+	sourceOpts := opts.Source
+	if sourceOpts != nil {
+		sourceOpts = slices.Concat(
+			[]kgo.Opt{kgo.FetchIsolationLevel(kgo.ReadCommitted())},
+			sourceOpts,
+			[]kgo.Opt{kgo.KeepControlRecords()},
+		)
+	}
+	sourceClient, sourceAdminClient, err := getClients(slices.Concat(sourceOpts, metrics.clientOpts(sideSource)))
+	if err != nil {
+		return fmt.Errorf("failed to create source Kafka client: %w", err)
+	}
+	defer sourceClient.Close()
+
+	targets := make([]*sinkTarget, 0, len(m.sinks))
+	defer func() {
+		for _, t := range targets {
+			t.close()
+		}
+	}()
+	for _, sink := range m.sinks {
+		t, err := m.openSink(sink, metrics)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t)
+	}
+
+	sourceLog.Info("Getting topics")
+	sourceTopics, err := m.getTopics(rootCtx, sourceAdminClient, m.names)
+	if err != nil {
+		return fmt.Errorf("failed to get source topics: %w", err)
+	}
+
+	sourceLog.Info("Checking topics")
+	if err := CheckTopics(sourceTopics, opts.Topics); err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if err := t.mirror.planSink(rootCtx, t, sourceAdminClient, sourceTopics, ReadsCommitted(sourceClient)); err != nil {
+			return err
+		}
+	}
+	if err := checkDistinctSinks(targets); err != nil {
+		return fmt.Errorf("refusing to use sink cluster: %w", err)
+	}
+
+	if opts.DryRun {
+		if len(opts.Sinks) == 0 {
+			return printPlan(opts.Output, targets[0].plan, opts.PlanFormat)
+		}
+		return printSinkPlans(opts.Output, targets, opts.PlanFormat)
+	}
+
+	// The source side of the mirror covers the topics of every sink.
+	plan := mergePlans(targets)
+
+	compactor, err := newCompactor(opts.Latest.Topics, plan, opts.Latest.Follow, m.newKeyStore)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	defer func() { _ = compactor.Close() }()
+
+	for _, t := range targets {
+		if err := t.mirror.prepareSink(rootCtx, t); err != nil {
+			return err
+		}
+	}
+
+	sourceLog.Info("Configuring consumer")
+	if opts.ExactlyOnce.TransactionalID != "" {
+		sourceClient.AddConsumePartitions(consumeCheckpointed(plan))
+	} else {
+		sourceClient.AddConsumePartitions(ConsumePartitions(sourceTopics, opts.Topics))
+	}
+
+	var progress *progressTracker
+	if opts.Progress != nil {
+		progressCtx, cancel := context.WithCancel(rootCtx)
+		defer cancel()
+
+		progress = newProgressTracker(plan, time.Now())
+		go progress.run(progressCtx, opts.ProgressInterval, opts.Progress)
+	}
+
+	pauser := newPauser(sourceClient, plan)
+	if opts.PauseSignals {
+		handlePauseSignals(rootCtx, pauser)
+	}
+	if opts.ControlAddr != "" {
+		slog.Info("Serving control endpoints", slog.String("addr", opts.ControlAddr))
+		server := pauser.serve(opts.ControlAddr)
+		defer func() { _ = server.Close() }()
+	}
+
+	// Fetching stops as soon as every sink is dropped, rather than with the
+	// next fetched records.
+	fetchCtx, stopFetching := context.WithCancel(rootCtx)
+	defer stopFetching()
+
+	for _, t := range targets {
+		t.onDrop = func() {
+			if !slices.ContainsFunc(targets, (*sinkTarget).active) {
+				stopFetching()
+			}
+		}
+		t.router = newRouter(t.plan)
+
+		if len(opts.Groups) > 0 || opts.OffsetMapFile != "" {
+			t.offsets = newOffsetMap(t.plan)
+		}
+
+		t.groups = newGroupSyncer(opts.Groups, sourceAdminClient, t.admin, t.offsets, t.mirror.sinkTopic, opts.Timeout)
+		if t.groups != nil {
+			t.groups.logger = t.log
+
+			groupsCtx, cancel := context.WithCancel(rootCtx)
+			defer cancel()
+
+			go t.groups.run(groupsCtx, opts.GroupSyncInterval)
+		}
+
+		if opts.PreserveCompression {
+			t.codecs = newCodecProducer(t.opts, metrics, sinkMetricsSide(t.name))
+		}
+	}
+
+	// Several sinks aren't supported with an offset map file, so there is
+	// only the map of the single sink to write.
+	if offsets := targets[0].offsets; opts.OffsetMapFile != "" {
+		offsetsCtx, cancel := context.WithCancel(rootCtx)
+		defer cancel()
+
+		go offsets.writeEvery(offsetsCtx, opts.OffsetMapFile, opts.OffsetMapInterval)
+
+		// The map is written however the mirror stops, as the records
+		// produced until then are on the sink.
+		defer func() {
+			if err := offsets.WriteFile(opts.OffsetMapFile); err != nil {
+				slog.Error("Failed to write offset map", slog.String("path", opts.OffsetMapFile), slog.Any("error", err))
+			}
+		}()
+	}
+
+	// Several sinks aren't supported exactly-once either. The transaction
+	// is aborted unless committed once the mirror stopped.
+	txn, err := newTransactor(opts.ExactlyOnce, targets[0], targets[0].resumed, opts.Timeout)
+	if err != nil {
+		return &SinkWriteError{Err: err}
+	}
+	defer txn.abort()
+
+	// Transactions are committed in the background until the mirror stops,
+	// and a last time with the last records.
+	stopCommits := func() {}
+	if txn != nil {
+		txnCtx, cancel := context.WithCancel(rootCtx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			txn.run(txnCtx, opts.ExactlyOnce.CommitInterval)
+		}()
+
+		stopCommits = func() {
+			cancel()
+			<-done
+		}
+		defer stopCommits()
+	}
+
+	defer sampler.logCounts(sourceLog)
+
+	// excluded counts the records of ExcludeOrigin, from the fetch loop.
+	var excluded int64
+	if opts.ExcludeOrigin != "" {
+		defer func() {
+			sourceLog.Info("Skipped records mirrored from the sink", slog.String("origin", opts.ExcludeOrigin), slog.Int64("records", excluded))
+		}()
+	}
+
+	// A record is produced to every sink mirroring its topic, each from a
+	// copy of the record when there are several.
+	produce := func(ctx context.Context, r *kgo.Record) error {
+		if err := throttle.wait(ctx, r); err != nil {
+			return fmt.Errorf("failed to wait for throttle: %w", err)
+		}
+
+		txn.produce(topicPartition{Topic: r.Topic, Partition: r.Partition}, r.Offset, func() {
+			for _, t := range targets {
+				if !t.mirrors(r.Topic) {
+					continue
+				}
+				if len(targets) == 1 {
+					t.produce(ctx, r)
+				} else {
+					t.produce(ctx, cloneRecord(r))
+				}
+			}
+		})
+		return nil
+	}
+
+	// Stopping the mirror doesn't cancel the records fetched so far: they
+	// are still produced and flushed, within the timeout.
+	pipeline := newPipeline(context.WithoutCancel(rootCtx), sourceClient, opts.PartitionQueue, produce)
+	defer pipeline.abort()
+
+	// Topics mirrored with only the latest record per key are produced as
+	// soon as every partition reached its high watermark at startup.
+	produceLatest := func() error {
+		for _, topic := range compactor.ready() {
+			keys, err := compactor.flush(topic, func(r *kgo.Record) error {
+				if err := rootCtx.Err(); err != nil {
+					return err
+				}
+				return produce(pipeline.ctx, r)
+			})
+			if err != nil {
+				return err
+			}
+			sourceLog.Info("Produced latest record per key", slog.String("topic", topic), slog.Int("keys", keys))
+
+			if !opts.Latest.Follow {
+				sourceClient.PurgeTopicsFromConsuming(topic)
+			}
+		}
+		return nil
+	}
+
+	slog.Info("Starting mirror")
+	for {
+		if err := produceLatest(); err != nil {
+			if rootCtx.Err() != nil {
+				break
+			}
+			return err
+		}
+		if compactor.done() && len(compactor.topics) == len(m.topics) {
+			slog.Info("Produced latest record per key of every topic")
+			break
+		}
+
+		if err := pipeline.err(); err != nil {
+			return err
+		}
+
+		// The mirror goes on as long as a sink is left, so the first failed
+		// produce of a single sink stops it rather than silently leaving a
+		// gap in the sink topic.
+		if !slices.ContainsFunc(targets, (*sinkTarget).active) {
+			return &SinkWriteError{Err: sinkFailures(targets)}
+		}
+
+		started := time.Now()
+		fetches := sourceClient.PollFetches(fetchCtx)
+		metrics.observeFetch(started)
+		if rootCtx.Err() != nil {
+			break
+		}
+		if fetchCtx.Err() != nil {
+			continue
+		}
+
+		fetches.EachError(func(s string, i int32, err error) {
+			sourceLog.LogAttrs(
+				rootCtx,
+				slog.LevelError,
+				"Failed to fetch topic",
+				slog.String("topic", s),
+				slog.Int("partition", int(i)),
+				slog.Any("error", err),
+			)
+		})
+
+		if err := fetches.Err(); err != nil {
+			return fmt.Errorf("failed to fetch records: %w", err)
+		}
+
+		sourceLog.LogAttrs(rootCtx, slog.LevelDebug, "Processing fetches", slog.Int("records", fetches.NumRecords()))
+
+		var absorbErr error
+		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+			metrics.observePartition(p)
+			progress.observe(p)
+
+			kept := make([]*kgo.Record, 0, len(p.Records))
+			for _, r := range p.Records {
+				if r.Attrs.IsControl() {
+					compactor.skip(r)
+					continue
+				}
+
+				metrics.consumed(r)
+				logRecord(rootCtx, sourceLog, opts.LogRecordSampling, "Consumed record", r)
+
+				if opts.ExcludeOrigin != "" && hasOrigin(r, opts.ExcludeOrigin) {
+					excluded++
+					compactor.skip(r)
+					continue
+				}
+
+				keep := sampler.keep(r)
+				metrics.sampled(r, keep)
+				if !keep {
+					compactor.skip(r)
+					continue
+				}
+
+				absorbed, err := compactor.absorb(r)
+				if err != nil {
+					absorbErr = err
+					return
+				}
+				if absorbed {
+					continue
+				}
+
+				kept = append(kept, r)
+			}
+
+			pipeline.send(topicPartition{Topic: p.Topic, Partition: p.Partition}, kept)
+		})
+		if absorbErr != nil {
+			return absorbErr
+		}
+	}
+
+	slog.Info("Stopping mirror")
+	flushCtx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	if err := pipeline.close(flushCtx); err != nil {
+		return err
+	}
+
+	stopCommits()
+	for _, t := range targets {
+		if t.active() && txn != nil {
+			if err := txn.commit(flushCtx); err != nil {
+				t.fail(err)
+			}
+		} else if t.active() {
+			t.flush(flushCtx)
+		}
+
+		// Every record is produced, so the offsets of the groups are
+		// synced a last time with the complete offset map.
+		if t.active() {
+			t.groups.sync(context.Background())
+		}
+
+		if len(opts.Sinks) > 0 {
+			t.log.Info("Delivered records", slog.Int64("delivered", t.delivered.Load()), slog.Int64("failed", t.failed.Load()))
+		}
+	}
+
+	if err := sinkFailures(targets); err != nil {
+		return &SinkWriteError{Err: err}
+	}
+	return nil
+}
+
+// openSink creates the clients of a sink.
+func (m *Mirror) openSink(sink sinkMirror, metrics *mirrorMetrics) (*sinkTarget, error) {
+	t := &sinkTarget{sinkMirror: sink, log: sideLogger(sideSink), metrics: metrics}
+	if sink.name != "" {
+		t.log = t.log.With(slog.String("sink", sink.name))
+	}
+
+	// Records are produced to the partition set by the mirror: their source
+	// partition, unless the topic is routed. Without sink options there are
+	// no brokers to produce to, which getClients reports.
+	t.opts = sink.mirror.opts.Sink
+	if t.opts != nil {
+		t.opts = slices.Concat(t.opts, []kgo.Opt{kgo.RecordPartitioner(kgo.ManualPartitioner())})
+		if id := sink.mirror.opts.ExactlyOnce.TransactionalID; id != "" {
+			t.opts = append(t.opts, kgo.TransactionalID(id))
+		}
+	}
+
+	t.log.Info("Creating Kafka client")
+	client, admin, err := getClients(slices.Concat(t.opts, metrics.clientOpts(sinkMetricsSide(sink.name))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sink Kafka client: %w", err)
+	}
+	t.client, t.admin = client, admin
+	return t, nil
+}
+
+// planSink checks the sink of t and plans the mirror of its topics, committed
+// telling whether the source only reads committed records.
+func (m *Mirror) planSink(rootCtx context.Context, t *sinkTarget, sourceAdminClient *kadm.Client, sourceTopics kadm.TopicDetails, committed bool) error {
+	t.log.Info("Getting topics")
+	sinkTopics, err := m.getTopics(rootCtx, t.admin, m.sinkNames)
+	if err != nil {
+		return fmt.Errorf("failed to get sink topics: %w", err)
+	}
+
+	t.log.Info("Checking cluster")
+	t.cluster, err = m.checkSink(rootCtx, sourceAdminClient, t.admin)
+	if err != nil {
+		return fmt.Errorf("refusing to use sink cluster: %w", err)
+	}
+
+	if m.opts.ExactlyOnce.TransactionalID != "" {
+		// A dry run leaves a running mirror of the same transactional ID
+		// alone, at the cost of checkpoints it may still commit.
+		if !m.opts.DryRun {
+			t.log.Info("Fencing previous transactions", slog.String("transactional_id", m.opts.ExactlyOnce.TransactionalID))
+			if err := m.fence(rootCtx, t.client); err != nil {
+				return err
+			}
+		}
+
+		t.log.Info("Reading checkpoints", slog.String("topic", m.opts.ExactlyOnce.CheckpointTopic))
+		t.resumed, err = m.readCheckpoints(rootCtx, t.admin)
+		if err != nil {
+			return err
+		}
+		if t.resumed != nil {
+			t.log.Info("Resuming from checkpoints", slog.Int("partitions", len(t.resumed)))
+		}
+	}
+
+	t.log.Info("Planning mirror")
+	t.plan, err = m.buildPlan(rootCtx, sourceAdminClient, t.admin, sourceTopics, sinkTopics, t.resumed, committed)
+	if err != nil {
+		return fmt.Errorf("failed to plan mirror: %w", err)
+	}
+	return nil
+}
+
+// prepareSink deletes and creates the topics of the plan of t, and its ACLs.
+func (m *Mirror) prepareSink(rootCtx context.Context, t *sinkTarget) error {
+	opts := &m.opts
+
+	if len(t.plan.Delete) > 0 && !opts.DeleteExisting {
+		if opts.ConfirmDeletion == nil {
+			return fmt.Errorf("refusing to delete sink topics %v without confirmation", t.plan.Delete)
+		}
+
+		confirmed, err := opts.ConfirmDeletion(t.cluster.String(), t.plan.Delete)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("deletion of sink topics %v was not confirmed", t.plan.Delete)
+		}
+	}
+
+	t.log.Info("Deleting existing topics", slog.Any("topics", t.plan.Delete))
+	if err := m.deleteExistingTopics(rootCtx, t.admin, t.plan.Delete); err != nil {
+		return &SinkWriteError{Err: fmt.Errorf("failed to delete existing sink topics: %w", err)}
+	}
+
+	var create, kept []string
+	for _, pt := range t.plan.Create {
+		if pt.Existing {
+			kept = append(kept, pt.sinkTopic())
+		} else {
+			create = append(create, pt.sinkTopic())
+		}
+	}
+	if len(kept) > 0 {
+		t.log.Info("Keeping existing topics", slog.Any("topics", kept))
+	}
+	t.log.Info("Creating topics", slog.Any("topics", create))
+	if err := m.createTopics(rootCtx, t.admin, t.plan.Create); err != nil {
+		return &SinkWriteError{Err: fmt.Errorf("failed to create sink topics: %w", err)}
+	}
+
+	if opts.ExactlyOnce.TransactionalID != "" {
+		if err := m.createCheckpointTopic(rootCtx, t.admin); err != nil {
+			return &SinkWriteError{Err: err}
+		}
+	}
+
+	if t.plan.ACLs != nil {
+		t.log.Info("Creating ACLs", slog.Int("acls", len(t.plan.ACLs)))
+		if err := m.createACLs(rootCtx, t.admin, t.plan.ACLs); err != nil {
+			return &SinkWriteError{Err: fmt.Errorf("failed to create sink ACLs: %w", err)}
+		}
+	}
+	return nil
+}
+
+// sinkFailures returns the errors the sinks were dropped for, nil if none
+// was.
+func sinkFailures(targets []*sinkTarget) error {
+	var errs []error
+	for _, t := range targets {
+		if err := t.failure(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Mirror) checkSink(rootCtx context.Context, sourceClient, sinkClient *kadm.Client) (clusterIdentity, error) {
+	sourceCluster, err := m.getClusterIdentity(rootCtx, sourceClient)
+	if err != nil {
+		return clusterIdentity{}, fmt.Errorf("failed to identify source cluster: %w", err)
+	}
+
+	sinkCluster, err := m.getClusterIdentity(rootCtx, sinkClient)
+	if err != nil {
+		return clusterIdentity{}, fmt.Errorf("failed to identify sink cluster: %w", err)
+	}
+
+	if err := checkDistinctClusters(sourceCluster, sinkCluster); err != nil {
+		return sinkCluster, err
+	}
+
+	if err := checkSinkAllowed(sinkCluster, m.opts.AllowSink); err != nil {
+		return sinkCluster, err
+	}
+
+	return sinkCluster, nil
+}
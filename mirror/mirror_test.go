@@ -0,0 +1,675 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func skipShort(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("integration test against fake clusters")
+	}
+}
+
+// testOptions returns the options mirroring the given topic arguments
+// between two fake clusters.
+func testOptions(t *testing.T, source, sink []string, topics ...string) Options {
+	t.Helper()
+
+	parsed, err := ParseTopics(topics)
+	if err != nil {
+		t.Fatalf("ParseTopics() error = %v", err)
+	}
+
+	return Options{
+		Source:         []kgo.Opt{kgo.SeedBrokers(source...)},
+		Sink:           []kgo.Opt{kgo.SeedBrokers(sink...)},
+		Topics:         parsed,
+		Timeout:        5 * time.Second,
+		DeleteExisting: true,
+	}
+}
+
+func newTestMirror(t *testing.T, opts Options) *Mirror {
+	t.Helper()
+
+	m, err := New(opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return m
+}
+
+// startMirror runs m, a Mirror, a FanIn or a Bidirectional, in the background and returns a
+// function stopping it and returning the error of Run.
+func startMirror(t *testing.T, m interface{ Run(context.Context) error }) func() error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- m.Run(ctx) }()
+
+	stopped := false
+	stop := func() error {
+		if stopped {
+			return nil
+		}
+		stopped = true
+		cancel()
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(10 * time.Second):
+			return errors.New("mirror did not stop")
+		}
+	}
+	t.Cleanup(func() { _ = stop() })
+	return stop
+}
+
+// waitForTopic waits until topic exists on the cluster with the given number
+// of partitions.
+func waitForTopic(t *testing.T, brokers []string, topic string, partitions int) {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	// The mirror sees sink topics through metadata cached for up to 5s, both
+	// when waiting for their deletion and for their creation.
+	adm := kadm.NewClient(client)
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		topics, err := adm.ListTopics(context.Background(), topic)
+		if err == nil && topics.Has(topic) && len(topics[topic].Partitions) == partitions {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("topic %q was not created", topic)
+}
+
+func recordValues(records []*kgo.Record) []string {
+	out := make([]string, 0, len(records))
+	for _, r := range records {
+		out = append(out, string(r.Value))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func produceValues(t *testing.T, brokers []string, topic string, values ...string) {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	records := make([]*kgo.Record, 0, len(values))
+	for _, v := range values {
+		records = append(records, &kgo.Record{Topic: topic, Value: []byte(v)})
+	}
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+}
+
+func TestMirror_Offsets(t *testing.T) {
+	skipShort(t)
+
+	tests := []struct {
+		name  string
+		topic string
+		want  []string
+	}{
+		{name: "start", topic: "orders@-2", want: []string{"v0", "v1", "v2", "v3", "v4", "v5"}},
+		{name: "absolute", topic: "orders@4", want: []string{"v4", "v5"}},
+		{name: "per partition", topic: "orders@0:2,1:0", want: []string{"v2", "v3", "v4", "v5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeCluster(t, 1, "orders")
+			sink := newFakeCluster(t, 1)
+			produceValues(t, source, "orders", "v0", "v1", "v2", "v3", "v4", "v5")
+
+			stop := startMirror(t, newTestMirror(t, testOptions(t, source, sink, tt.topic)))
+			got := consumeRecords(t, sink, len(tt.want), "orders")
+			if err := stop(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if values := recordValues(got); !slices.Equal(values, tt.want) {
+				t.Errorf("sink records = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirror_Tail(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "old")
+
+	stop := startMirror(t, newTestMirror(t, testOptions(t, source, sink, "orders")))
+	waitForTopic(t, sink, "orders", 1)
+	// The consumer starts at the end once the sink topic exists; give it a
+	// moment to list offsets before producing.
+	time.Sleep(500 * time.Millisecond)
+	produceValues(t, source, "orders", "new1", "new2")
+
+	got := consumeRecords(t, sink, 2, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if values := recordValues(got); !slices.Equal(values, []string{"new1", "new2"}) {
+		t.Errorf("sink records = %v, want [new1 new2]", values)
+	}
+}
+
+func TestMirror_MissingTopics(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+
+	err := newTestMirror(t, testOptions(t, source, sink, "orders", "payments")).Run(context.Background())
+
+	var missing *MissingTopicError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Run() error = %v, want *MissingTopicError", err)
+	}
+	if !slices.Equal(missing.Topics, []string{"payments"}) {
+		t.Errorf("missing topics = %v, want [payments]", missing.Topics)
+	}
+}
+
+func TestMirror_RecreatesSinkTopic(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 2, "orders")
+	sink := newFakeCluster(t, 1, "orders")
+	produceValues(t, source, "orders", "v0", "v1", "v2")
+	produceValues(t, sink, "orders", "stale0", "stale1")
+
+	stop := startMirror(t, newTestMirror(t, testOptions(t, source, sink, "orders@-2")))
+	// The sink topic is recreated with the partitions of the source topic.
+	waitForTopic(t, sink, "orders", 2)
+	got := consumeRecords(t, sink, 3, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if values := recordValues(got); !slices.Equal(values, []string{"v0", "v1", "v2"}) {
+		t.Errorf("sink records = %v, want [v0 v1 v2]", values)
+	}
+}
+
+func TestMirror_RefusesDeletionWithoutConfirmation(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1, "orders")
+
+	opts := testOptions(t, source, sink, "orders")
+	opts.DeleteExisting = false
+
+	if err := newTestMirror(t, opts).Run(context.Background()); err == nil || !strings.Contains(err.Error(), "without confirmation") {
+		t.Errorf("Run() error = %v, want refusal without confirmation", err)
+	}
+}
+
+func TestMirror_RecordFidelity(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 3, "orders")
+	sink := newFakeCluster(t, 1)
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(source...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	timestamp := time.UnixMilli(1700000000000)
+	var produced []*kgo.Record
+	for i := range 20 {
+		produced = append(produced, &kgo.Record{
+			Topic:     "orders",
+			Key:       fmt.Appendf(nil, "key-%d", i),
+			Value:     fmt.Appendf(nil, "value-%d", i),
+			Timestamp: timestamp.Add(time.Duration(i) * time.Second),
+			Headers: []kgo.RecordHeader{
+				{Key: "trace", Value: fmt.Appendf(nil, "trace-%d", i)},
+				{Key: "empty"},
+			},
+		})
+	}
+	if err := client.ProduceSync(context.Background(), produced...).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	stop := startMirror(t, newTestMirror(t, testOptions(t, source, sink, "orders@-2")))
+	got := consumeRecords(t, sink, len(produced), "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	byKey := map[string]*kgo.Record{}
+	for _, r := range got {
+		byKey[string(r.Key)] = r
+	}
+
+	for _, want := range produced {
+		r, ok := byKey[string(want.Key)]
+		if !ok {
+			t.Errorf("record %s was not mirrored", want.Key)
+			continue
+		}
+
+		if string(r.Value) != string(want.Value) {
+			t.Errorf("record %s value = %q, want %q", want.Key, r.Value, want.Value)
+		}
+		if !r.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("record %s timestamp = %v, want %v", want.Key, r.Timestamp, want.Timestamp)
+		}
+		if r.Partition != want.Partition {
+			t.Errorf("record %s partition = %d, want %d", want.Key, r.Partition, want.Partition)
+		}
+		if len(r.Headers) != len(want.Headers) {
+			t.Errorf("record %s headers = %v, want %v", want.Key, r.Headers, want.Headers)
+			continue
+		}
+		for i, h := range r.Headers {
+			if h.Key != want.Headers[i].Key || string(h.Value) != string(want.Headers[i].Value) {
+				t.Errorf("record %s header %d = %v, want %v", want.Key, i, h, want.Headers[i])
+			}
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	valid := func() Options {
+		return Options{Topics: []Topic{{Name: "orders", Offset: -1}}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Options)
+	}{
+		{"no topics", func(o *Options) { o.Topics = nil }},
+		{"plan format", func(o *Options) { o.PlanFormat = "yaml" }},
+		{"latest store", func(o *Options) { o.Latest.Store = "s3" }},
+		{"log record sampling", func(o *Options) { o.LogRecordSampling = 2 }},
+		{"throttle", func(o *Options) { o.Throttle.TopicMaxRecordsPerSec = map[string]float64{"orders": -1} }},
+		{"sample", func(o *Options) { o.Sample = map[string]string{"orders": "nth=0"} }},
+		{"group", func(o *Options) { o.Groups = []Group{{Sink: "orders-local"}} }},
+		{"replication factor", func(o *Options) { o.ReplicationFactor = 2; o.SourceReplicationFactor = true }},
+		{"min insync replicas", func(o *Options) { o.MinInsyncReplicas = -1 }},
+		{"partition routing", func(o *Options) { o.PartitionRouting = "random" }},
+		{"sink partitions", func(o *Options) { o.SinkPartitions = map[string]int32{"orders": 0} }},
+		{"sink partitions of a topic that isn't mirrored", func(o *Options) { o.SinkPartitions = map[string]int32{"users": 2} }},
+		{"principal rewrite", func(o *Options) { o.PrincipalRewrites = []PrincipalRewrite{{From: "User:orders"}} }},
+		{"sink and sinks", func(o *Options) { o.Sink = []kgo.Opt{}; o.Sinks = []Sink{{Name: "alice"}} }},
+		{"unnamed sink", func(o *Options) { o.Sinks = []Sink{{}} }},
+		{"duplicate sink", func(o *Options) { o.Sinks = []Sink{{Name: "alice"}, {Name: "alice"}} }},
+		{"sink topic that isn't mirrored", func(o *Options) { o.Sinks = []Sink{{Name: "alice", Topics: []string{"users"}}} }},
+		{"topic without a sink", func(o *Options) {
+			o.Topics = append(o.Topics, Topic{Name: "users"})
+			o.Sinks = []Sink{{Name: "alice", Topics: []string{"orders"}}}
+		}},
+		{"offset map with sinks", func(o *Options) { o.OffsetMapFile = "offsets.json"; o.Sinks = []Sink{{Name: "alice"}} }},
+		{"groups keeping sink topics", func(o *Options) { o.KeepSinkTopics = true; o.Groups = []Group{{Source: "orders-service"}} }},
+		{"offset map keeping sink topics", func(o *Options) { o.KeepSinkTopics = true; o.OffsetMapFile = "offsets.json" }},
+	}
+
+	if _, err := New(valid()); err != nil {
+		t.Fatalf("New() error = %v for valid options", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid()
+			tt.modify(&opts)
+
+			var configErr *ConfigError
+			if _, err := New(opts); !errors.As(err, &configErr) {
+				t.Errorf("New() error = %v, want *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestMirror_DryRun(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 2, "orders")
+	sink := newFakeCluster(t, 1, "orders")
+	produceValues(t, source, "orders", "v0", "v1")
+
+	var out strings.Builder
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.DryRun = true
+	opts.PlanFormat = "json"
+	opts.Output = &out
+
+	if err := newTestMirror(t, opts).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `"delete": [`) || !strings.Contains(out.String(), `"topic": "orders"`) {
+		t.Errorf("dry-run plan = %s, want orders deleted and created", out.String())
+	}
+}
+
+func TestMirror_ConfirmDeletion(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1, "orders")
+
+	var asked []string
+	opts := testOptions(t, source, sink, "orders")
+	opts.DeleteExisting = false
+	opts.ConfirmDeletion = func(_ string, topics []string) (bool, error) {
+		asked = topics
+		return false, nil
+	}
+
+	if err := newTestMirror(t, opts).Run(context.Background()); err == nil || !strings.Contains(err.Error(), "not confirmed") {
+		t.Errorf("Run() error = %v, want deletion not confirmed", err)
+	}
+	if !slices.Equal(asked, []string{"orders"}) {
+		t.Errorf("ConfirmDeletion() asked for %v, want [orders]", asked)
+	}
+}
+
+func TestMirror_Progress(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "v0", "v1", "v2")
+
+	reports := make(chan []PartitionProgress, 16)
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.ProgressInterval = 50 * time.Millisecond
+	opts.Progress = func(progress []PartitionProgress) {
+		select {
+		case reports <- progress:
+		default:
+		}
+	}
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	consumeRecords(t, sink, 3, "orders")
+
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case progress := <-reports:
+			if len(progress) != 1 || progress[0].Topic != "orders" {
+				t.Fatalf("progress = %+v, want partition 0 of orders", progress)
+			}
+			if progress[0].Offset < 3 {
+				continue
+			}
+			if err := stop(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			return
+		case <-deadline:
+			t.Fatal("progress never reached the high watermark")
+		}
+	}
+}
+
+func TestMirror_StopProducesFetchedRecords(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "v0", "v1", "v2")
+
+	fetched := make(chan struct{})
+	var once sync.Once
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.Sink = append(opts.Sink, kgo.ProducerLinger(30*time.Second))
+	opts.ProgressInterval = 50 * time.Millisecond
+	opts.Progress = func(progress []PartitionProgress) {
+		if len(progress) == 1 && progress[0].Offset >= 3 {
+			once.Do(func() { close(fetched) })
+		}
+	}
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	select {
+	case <-fetched:
+	case <-time.After(30 * time.Second):
+		t.Fatal("records were never fetched")
+	}
+
+	// The records are still lingering in the sink client when the mirror
+	// is stopped.
+	if end := endOffset(t, sink, "orders"); end != 0 {
+		t.Fatalf("end offset of orders on the sink = %d before stopping, want 0", end)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := recordValues(consumeRecords(t, sink, 3, "orders")); !slices.Equal(got, []string{"v0", "v1", "v2"}) {
+		t.Errorf("mirrored records = %v, want [v0 v1 v2]", got)
+	}
+}
+
+func TestMirror_Groups(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "v0", "v1", "v2", "v3", "v4", "v5")
+
+	sourceClient, err := kgo.NewClient(kgo.SeedBrokers(source...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer sourceClient.Close()
+
+	// The service consumed v0 to v3 on the source.
+	offsets := kadm.Offsets{}
+	offsets.Add(kadm.Offset{Topic: "orders", Partition: 0, At: 4, LeaderEpoch: -1})
+	if err := kadm.NewClient(sourceClient).CommitAllOffsets(context.Background(), "orders-service", offsets); err != nil {
+		t.Fatalf("CommitAllOffsets() error = %v", err)
+	}
+
+	// Mirroring from offset 2, v4 is at offset 2 on the sink.
+	opts := testOptions(t, source, sink, "orders@2")
+	opts.Groups = []Group{{Source: "orders-service", Sink: "orders-local"}}
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	consumeRecords(t, sink, 4, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	sinkClient, err := kgo.NewClient(kgo.SeedBrokers(sink...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer sinkClient.Close()
+
+	fetched, err := kadm.NewClient(sinkClient).FetchOffsets(context.Background(), "orders-local")
+	if err != nil {
+		t.Fatalf("FetchOffsets() error = %v", err)
+	}
+	got, ok := fetched.Lookup("orders", 0)
+	if !ok || got.At != 2 {
+		t.Errorf("sink group offset = %+v, want 2", got)
+	}
+}
+
+func TestMirror_OffsetMap(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "v0", "v1", "v2", "v3", "v4", "v5")
+
+	opts := testOptions(t, source, sink, "orders@2")
+	opts.OffsetMapFile = filepath.Join(t.TempDir(), "offsets.json")
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	consumeRecords(t, sink, 4, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	m, err := ReadOffsetMap(opts.OffsetMapFile)
+	if err != nil {
+		t.Fatalf("ReadOffsetMap() error = %v", err)
+	}
+
+	// Mirroring from offset 2, v4 is at offset 2 on the sink.
+	got, mirrored, ok := m.Translate("orders", 0, 4)
+	if !ok || !mirrored || got != 2 {
+		t.Errorf("Translate(orders, 0, 4) = %d, %v, %v, want 2, true, true", got, mirrored, ok)
+	}
+	if _, mirrored, _ := m.Translate("orders", 0, 1); mirrored {
+		t.Error("Translate(orders, 0, 1) reported a record before the start offset as mirrored")
+	}
+}
+
+func TestMirror_ReplicationFactor(t *testing.T) {
+	skipShort(t)
+
+	cluster, err := kfake.NewCluster(
+		kfake.NumBrokers(3),
+		kfake.ClusterID(fmt.Sprintf("kfake-%d", fakeClusters.Add(1))),
+		kfake.SeedTopics(1, "orders"),
+	)
+	if err != nil {
+		t.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	source := cluster.ListenAddrs()
+
+	sourceClient, err := kgo.NewClient(kgo.SeedBrokers(source...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer sourceClient.Close()
+
+	minInsync := "2"
+	if _, err := kadm.NewClient(sourceClient).AlterTopicConfigs(context.Background(), []kadm.AlterConfig{
+		{Op: kadm.SetConfig, Name: "min.insync.replicas", Value: &minInsync},
+	}, "orders"); err != nil {
+		t.Fatalf("AlterTopicConfigs() error = %v", err)
+	}
+
+	// The source topic has 3 replicas, the sink a single broker.
+	sink := newFakeCluster(t, 1)
+	produceValues(t, source, "orders", "v0", "v1")
+
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.SourceReplicationFactor = true
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	consumeRecords(t, sink, 2, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	sinkClient, err := kgo.NewClient(kgo.SeedBrokers(sink...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer sinkClient.Close()
+	sinkAdmin := kadm.NewClient(sinkClient)
+
+	topics, err := sinkAdmin.ListTopics(context.Background(), "orders")
+	if err != nil {
+		t.Fatalf("ListTopics() error = %v", err)
+	}
+	if got := topics["orders"].Partitions.NumReplicas(); got != 1 {
+		t.Errorf("sink replication factor = %d, want 1", got)
+	}
+
+	configs, err := sinkAdmin.DescribeTopicConfigs(context.Background(), "orders")
+	if err != nil {
+		t.Fatalf("DescribeTopicConfigs() error = %v", err)
+	}
+	config, err := configs.On("orders", nil)
+	if err != nil {
+		t.Fatalf("DescribeTopicConfigs() error = %v", err)
+	}
+	var got string
+	for _, c := range config.Configs {
+		if c.Key == "min.insync.replicas" {
+			got = c.MaybeValue()
+		}
+	}
+	if got != "1" {
+		t.Errorf("sink min.insync.replicas = %q, want 1", got)
+	}
+}
+
+func TestMirror_SinkPartitions(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 4, "orders")
+	sink := newFakeCluster(t, 1)
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(source...), kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	var records []*kgo.Record
+	for partition := range int32(4) {
+		records = append(records, &kgo.Record{Topic: "orders", Partition: partition, Value: []byte(fmt.Sprint(partition))})
+	}
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.SinkPartitions = map[string]int32{"orders": 2}
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	got := consumeRecords(t, sink, 4, "orders")
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	waitForTopic(t, sink, "orders", 2)
+
+	for _, r := range got {
+		var sourcePartition int32
+		if _, err := fmt.Sscan(string(r.Value), &sourcePartition); err != nil {
+			t.Fatalf("unexpected value %q", r.Value)
+		}
+		if r.Partition != sourcePartition%2 {
+			t.Errorf("record of source partition %d is in sink partition %d, want %d", sourcePartition, r.Partition, sourcePartition%2)
+		}
+	}
+}
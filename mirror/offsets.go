@@ -0,0 +1,226 @@
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// offsetRun is a run of records mirrored without gaps, at consecutive offsets
+// on both the source and the sink.
+type offsetRun struct {
+	Source int64 `json:"source"`
+	Sink   int64 `json:"sink"`
+	Count  int64 `json:"count"`
+}
+
+// partitionOffsets maps the offsets of a source partition to the offsets of
+// its sink partition.
+type partitionOffsets struct {
+	runs []offsetRun
+	// next is the sink offset of the next mirrored record.
+	next int64
+}
+
+// OffsetMap maps source offsets to sink offsets, from the records produced
+// so far. Sink topics are recreated, so their offsets start at zero and
+// differ from the source offsets whenever the mirror doesn't start at the
+// beginning of a partition, or records are skipped. Topics routed to a
+// different number of sink partitions aren't mapped, as their records don't
+// stay in their source partition. A nil *OffsetMap is valid and maps
+// nothing.
+type OffsetMap struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+func newOffsetMap(plan mirrorPlan) *OffsetMap {
+	m := &OffsetMap{partitions: map[topicPartition]*partitionOffsets{}}
+	for _, topic := range plan.Create {
+		if topic.Routing != "" {
+			continue
+		}
+		for _, p := range topic.Offsets {
+			// The first record mirrored from the start offset is the first
+			// record of the sink partition.
+			m.partitions[topicPartition{Topic: topic.Topic, Partition: p.Partition}] = &partitionOffsets{
+				runs: []offsetRun{{Source: p.StartOffset}},
+			}
+		}
+	}
+	return m
+}
+
+// record maps the source offset of a produced record to its sink offset.
+// Records of a partition must be recorded in order. Records of partitions
+// that aren't mapped are ignored.
+func (m *OffsetMap) record(tp topicPartition, source, sink int64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	po, ok := m.partitions[tp]
+	if !ok {
+		return
+	}
+
+	if n := len(po.runs); n > 0 {
+		last := &po.runs[n-1]
+		if last.Source+last.Count == source && last.Sink+last.Count == sink {
+			last.Count++
+			po.next = sink + 1
+			return
+		}
+		if last.Count == 0 {
+			po.runs = po.runs[:n-1]
+		}
+	}
+
+	po.runs = append(po.runs, offsetRun{Source: source, Sink: sink, Count: 1})
+	po.next = sink + 1
+}
+
+// Translate returns the sink offset of the record at offset in the source
+// partition, and whether that record was mirrored. If it wasn't, e.g.
+// because it was sampled out or isn't mirrored yet, the sink offset is the
+// one of the first record mirrored after it, or the next sink offset if none
+// is, which is where a consumer resuming from the source offset resumes on
+// the sink. ok is false if the partition isn't mirrored.
+func (m *OffsetMap) Translate(topic string, partition int32, offset int64) (sink int64, mirrored, ok bool) {
+	if m == nil {
+		return 0, false, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	po, ok := m.partitions[topicPartition{Topic: topic, Partition: partition}]
+	if !ok {
+		return 0, false, false
+	}
+
+	i := sort.Search(len(po.runs), func(i int) bool {
+		run := po.runs[i]
+		return run.Source+run.Count > offset
+	})
+	if i == len(po.runs) {
+		return po.next, false, true
+	}
+
+	run := po.runs[i]
+	if offset < run.Source {
+		return run.Sink, false, true
+	}
+	return run.Sink + offset - run.Source, true, true
+}
+
+// offsetMapFile is the JSON encoding of an OffsetMap.
+type offsetMapFile struct {
+	Partitions []offsetMapPartition `json:"partitions"`
+}
+
+type offsetMapPartition struct {
+	Topic     string      `json:"topic"`
+	Partition int32       `json:"partition"`
+	Next      int64       `json:"next"`
+	Runs      []offsetRun `json:"runs"`
+}
+
+// ReadOffsetMap reads an offset map written by a mirror with
+// Options.OffsetMapFile set.
+func ReadOffsetMap(path string) (*OffsetMap, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offset map: %w", err)
+	}
+
+	var file offsetMapFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode offset map %s: %w", path, err)
+	}
+
+	m := &OffsetMap{partitions: make(map[topicPartition]*partitionOffsets, len(file.Partitions))}
+	for _, p := range file.Partitions {
+		m.partitions[topicPartition{Topic: p.Topic, Partition: p.Partition}] = &partitionOffsets{
+			runs: p.Runs,
+			next: p.Next,
+		}
+	}
+	return m, nil
+}
+
+// WriteFile writes the offset map to path, replacing it atomically so a
+// reader never sees a partially written map.
+func (m *OffsetMap) WriteFile(path string) error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	file := offsetMapFile{Partitions: make([]offsetMapPartition, 0, len(m.partitions))}
+	for tp, po := range m.partitions {
+		file.Partitions = append(file.Partitions, offsetMapPartition{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			Next:      po.next,
+			Runs:      slices.Clone(po.runs),
+		})
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(file.Partitions, func(a, b offsetMapPartition) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode offset map: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write offset map: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write offset map: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write offset map: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write offset map: %w", err)
+	}
+	return nil
+}
+
+// writeEvery writes the offset map to path every interval until ctx is done.
+// Failures are logged, as the map is written again on the next tick.
+func (m *OffsetMap) writeEvery(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.WriteFile(path); err != nil {
+				slog.Error("Failed to write offset map", slog.String("path", path), slog.Any("error", err))
+			}
+		}
+	}
+}
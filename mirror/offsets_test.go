@@ -0,0 +1,137 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOffsetMap_Translate(t *testing.T) {
+	orders := topicPartition{Topic: "orders", Partition: 0}
+	m := newOffsetMap(mirrorPlan{Create: []plannedTopic{{
+		Topic:   "orders",
+		Offsets: []plannedPartition{{Partition: 0, StartOffset: 10}},
+	}}})
+
+	// Nothing is mirrored yet: every offset resumes at the start of the sink.
+	if got, _, _ := m.Translate("orders", 0, 15); got != 0 {
+		t.Errorf("Translate(15) before mirroring = %d, want 0", got)
+	}
+
+	// Source offsets 10-12 and 15-16 are mirrored, 13 and 14 are skipped.
+	for i, source := range []int64{10, 11, 12, 15, 16} {
+		m.record(orders, source, int64(i))
+	}
+
+	tests := []struct {
+		source   int64
+		want     int64
+		mirrored bool
+	}{
+		{5, 0, false},
+		{10, 0, true},
+		{12, 2, true},
+		{13, 3, false},
+		{14, 3, false},
+		{16, 4, true},
+		{17, 5, false},
+		{100, 5, false},
+	}
+
+	for _, tt := range tests {
+		got, mirrored, ok := m.Translate("orders", 0, tt.source)
+		if !ok || got != tt.want || mirrored != tt.mirrored {
+			t.Errorf("Translate(%d) = %d, %v, %v, want %d, %v, true", tt.source, got, mirrored, ok, tt.want, tt.mirrored)
+		}
+	}
+
+	if _, _, ok := m.Translate("users", 0, 0); ok {
+		t.Error("Translate() of a partition that isn't mirrored reported ok")
+	}
+}
+
+func TestOffsetMap_Runs(t *testing.T) {
+	orders := topicPartition{Topic: "orders", Partition: 0}
+	m := newOffsetMap(mirrorPlan{Create: []plannedTopic{{
+		Topic:   "orders",
+		Offsets: []plannedPartition{{Partition: 0, StartOffset: 0}},
+	}}})
+
+	for offset := range int64(1000) {
+		m.record(orders, offset, offset)
+	}
+	if runs := m.partitions[orders].runs; len(runs) != 1 || runs[0].Count != 1000 {
+		t.Errorf("runs = %+v, want a single run of 1000 records", runs)
+	}
+}
+
+func TestOffsetMap_Nil(t *testing.T) {
+	var m *OffsetMap
+	m.record(topicPartition{Topic: "orders"}, 1, 0)
+	if _, _, ok := m.Translate("orders", 0, 1); ok {
+		t.Error("Translate() of a nil map reported ok")
+	}
+	if err := m.WriteFile(filepath.Join(t.TempDir(), "offsets.json")); err != nil {
+		t.Errorf("WriteFile() of a nil map error = %v", err)
+	}
+}
+
+func TestOffsetMap_WriteFile(t *testing.T) {
+	m := newOffsetMap(mirrorPlan{Create: []plannedTopic{
+		{Topic: "orders", Offsets: []plannedPartition{{Partition: 0, StartOffset: 10}, {Partition: 1, StartOffset: 0}}},
+		{Topic: "users", Offsets: []plannedPartition{{Partition: 0, StartOffset: 3}}},
+	}})
+	for i, source := range []int64{10, 11, 15} {
+		m.record(topicPartition{Topic: "orders", Partition: 0}, source, int64(i))
+	}
+	m.record(topicPartition{Topic: "users", Partition: 0}, 3, 0)
+
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	if err := m.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	read, err := ReadOffsetMap(path)
+	if err != nil {
+		t.Fatalf("ReadOffsetMap() error = %v", err)
+	}
+
+	tests := []struct {
+		topic     string
+		partition int32
+		source    int64
+	}{
+		{"orders", 0, 9},
+		{"orders", 0, 11},
+		{"orders", 0, 12},
+		{"orders", 0, 15},
+		{"orders", 0, 16},
+		{"orders", 1, 0},
+		{"users", 0, 3},
+		{"users", 1, 0},
+	}
+
+	for _, tt := range tests {
+		wantSink, wantMirrored, wantOK := m.Translate(tt.topic, tt.partition, tt.source)
+		sink, mirrored, ok := read.Translate(tt.topic, tt.partition, tt.source)
+		if sink != wantSink || mirrored != wantMirrored || ok != wantOK {
+			t.Errorf("Translate(%s, %d, %d) after reading = %d, %v, %v, want %d, %v, %v",
+				tt.topic, tt.partition, tt.source, sink, mirrored, ok, wantSink, wantMirrored, wantOK)
+		}
+	}
+}
+
+func TestReadOffsetMap_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadOffsetMap(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("ReadOffsetMap() of a missing file succeeded")
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOffsetMap(path); err == nil {
+		t.Error("ReadOffsetMap() of an invalid file succeeded")
+	}
+}
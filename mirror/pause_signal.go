@@ -0,0 +1,33 @@
+//go:build !windows
+
+package mirror
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignals pauses fetching on SIGUSR1 and resumes it on SIGUSR2
+// until ctx is done.
+func handlePauseSignals(ctx context.Context, p *pauser) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					p.pause()
+				} else {
+					p.resume()
+				}
+			}
+		}
+	}()
+}
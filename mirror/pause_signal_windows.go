@@ -0,0 +1,9 @@
+//go:build windows
+
+package mirror
+
+import "context"
+
+// handlePauseSignals is a no-op on Windows, which has no SIGUSR1/SIGUSR2;
+// use the control endpoint instead.
+func handlePauseSignals(context.Context, *pauser) {}
@@ -0,0 +1,185 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fetchPauser pauses and resumes fetching of partitions, as *kgo.Client does.
+type fetchPauser interface {
+	PauseFetchPartitions(map[string][]int32) map[string][]int32
+	ResumeFetchPartitions(map[string][]int32)
+}
+
+// pipeline produces fetched records with one worker per partition, so a slow
+// partition, e.g. of a throttled topic, doesn't hold back the others. Each
+// worker produces the records of its partition in order.
+//
+// A worker has a bounded queue of fetched batches. When it is full, the batch
+// is set aside and fetching of the partition is paused until the worker has
+// caught up, instead of blocking the fetch loop.
+type pipeline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	source  fetchPauser
+	depth   int
+	produce func(context.Context, *kgo.Record) error
+
+	workers map[topicPartition]*partitionWorker
+	wg      sync.WaitGroup
+
+	errOnce  sync.Once
+	firstErr error
+	stopOnce sync.Once
+}
+
+// partitionWorker holds the records of a partition waiting to be produced.
+type partitionWorker struct {
+	tp      topicPartition
+	batches chan []*kgo.Record
+
+	mu sync.Mutex
+	// spill holds the records fetched while batches was full, produced once
+	// batches is drained.
+	spill []*kgo.Record
+}
+
+func newPipeline(ctx context.Context, source fetchPauser, depth int, produce func(context.Context, *kgo.Record) error) *pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	return &pipeline{
+		ctx:     ctx,
+		cancel:  cancel,
+		source:  source,
+		depth:   max(depth, 1),
+		produce: produce,
+		workers: map[topicPartition]*partitionWorker{},
+	}
+}
+
+// send queues records fetched from a partition for its worker. It must be
+// called from a single goroutine.
+func (p *pipeline) send(tp topicPartition, records []*kgo.Record) {
+	if len(records) == 0 {
+		return
+	}
+
+	w, ok := p.workers[tp]
+	if !ok {
+		w = &partitionWorker{tp: tp, batches: make(chan []*kgo.Record, p.depth)}
+		p.workers[tp] = w
+		p.wg.Add(1)
+		go p.run(w)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.spill != nil {
+		w.spill = append(w.spill, records...)
+		return
+	}
+
+	select {
+	case w.batches <- records:
+	default:
+		w.spill = records
+		p.source.PauseFetchPartitions(map[string][]int32{tp.Topic: {tp.Partition}})
+	}
+}
+
+func (p *pipeline) run(w *partitionWorker) {
+	defer p.wg.Done()
+
+	for batch := range w.batches {
+		p.produceAll(batch)
+		if len(w.batches) == 0 {
+			p.produceSpill(w)
+		}
+	}
+	p.produceSpill(w)
+}
+
+// produceSpill produces the records set aside while the queue of w was full
+// and resumes fetching of its partition.
+func (p *pipeline) produceSpill(w *partitionWorker) {
+	w.mu.Lock()
+	spill := w.spill
+	w.spill = nil
+	w.mu.Unlock()
+
+	if spill == nil {
+		return
+	}
+
+	p.produceAll(spill)
+	p.source.ResumeFetchPartitions(map[string][]int32{w.tp.Topic: {w.tp.Partition}})
+}
+
+func (p *pipeline) produceAll(records []*kgo.Record) {
+	for _, r := range records {
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		if err := p.produce(p.ctx, r); err != nil {
+			// Failures caused by stopping the mirror aren't errors.
+			if p.ctx.Err() == nil {
+				p.fail(err)
+			}
+			return
+		}
+	}
+}
+
+func (p *pipeline) fail(err error) {
+	p.errOnce.Do(func() {
+		p.firstErr = err
+		p.cancel()
+	})
+}
+
+// err returns the first error of a worker, which stopped the pipeline.
+func (p *pipeline) err() error {
+	select {
+	case <-p.ctx.Done():
+	default:
+		return nil
+	}
+
+	return p.firstErr
+}
+
+// close waits for the queued records to be produced and stops the workers.
+// Once ctx is done, the records still queued are dropped and close returns a
+// *TimeoutError.
+func (p *pipeline) close(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		for _, w := range p.workers {
+			close(w.batches)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.wg.Wait()
+	}()
+
+	select {
+	case <-done:
+		return p.err()
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return &TimeoutError{Err: fmt.Errorf("failed to produce fetched records before stopping: %w", ctx.Err())}
+	}
+}
+
+// abort stops the workers without producing the queued records.
+func (p *pipeline) abort() {
+	p.cancel()
+	_ = p.close(context.Background())
+}
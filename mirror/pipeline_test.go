@@ -0,0 +1,272 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// recordingPauser records the partitions paused and resumed by a pipeline.
+type recordingPauser struct {
+	mu      sync.Mutex
+	paused  []topicPartition
+	resumed []topicPartition
+}
+
+func (p *recordingPauser) PauseFetchPartitions(m map[string][]int32) map[string][]int32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for topic, partitions := range m {
+		for _, partition := range partitions {
+			p.paused = append(p.paused, topicPartition{Topic: topic, Partition: partition})
+		}
+	}
+	return nil
+}
+
+func (p *recordingPauser) ResumeFetchPartitions(m map[string][]int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for topic, partitions := range m {
+		for _, partition := range partitions {
+			p.resumed = append(p.resumed, topicPartition{Topic: topic, Partition: partition})
+		}
+	}
+}
+
+func testBatch(topic string, partition int32, from, to int64) []*kgo.Record {
+	var out []*kgo.Record
+	for offset := from; offset < to; offset++ {
+		out = append(out, &kgo.Record{Topic: topic, Partition: partition, Offset: offset})
+	}
+	return out
+}
+
+func TestPipeline_SlowPartition(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		produced = map[topicPartition][]int64{}
+	)
+	gate := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	source := &recordingPauser{}
+	p := newPipeline(context.Background(), source, 2, func(_ context.Context, r *kgo.Record) error {
+		if r.Topic == "slow" {
+			<-gate
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		tp := topicPartition{Topic: r.Topic, Partition: r.Partition}
+		produced[tp] = append(produced[tp], r.Offset)
+		if r.Topic == "fast" && len(produced[tp]) == 50 {
+			close(fastDone)
+		}
+		return nil
+	})
+
+	slow := topicPartition{Topic: "slow", Partition: 0}
+	fast := topicPartition{Topic: "fast", Partition: 0}
+	for i := range int64(5) {
+		p.send(slow, testBatch("slow", 0, i*10, i*10+10))
+		p.send(fast, testBatch("fast", 0, i*10, i*10+10))
+	}
+
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("records of the fast partition were not produced while the slow partition was blocked")
+	}
+
+	source.mu.Lock()
+	paused := slices.Clone(source.paused)
+	source.mu.Unlock()
+	if !slices.Contains(paused, slow) {
+		t.Errorf("paused partitions = %v, want %v among them", paused, slow)
+	}
+
+	close(gate)
+	if err := p.close(context.Background()); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	want := testBatch("slow", 0, 0, 50)
+	var wantOffsets []int64
+	for _, r := range want {
+		wantOffsets = append(wantOffsets, r.Offset)
+	}
+	if !slices.Equal(produced[slow], wantOffsets) {
+		t.Errorf("slow partition produced offsets %v, want %v", produced[slow], wantOffsets)
+	}
+	for _, tp := range source.paused {
+		if !slices.Contains(source.resumed, tp) {
+			t.Errorf("partition %v was paused and never resumed", tp)
+		}
+	}
+}
+
+func TestPipeline_Error(t *testing.T) {
+	errProduce := errors.New("produce failed")
+
+	p := newPipeline(context.Background(), &recordingPauser{}, 1, func(_ context.Context, r *kgo.Record) error {
+		if r.Offset == 3 {
+			return errProduce
+		}
+		return nil
+	})
+	defer p.abort()
+
+	p.send(topicPartition{Topic: "orders", Partition: 0}, testBatch("orders", 0, 0, 10))
+
+	if err := p.close(context.Background()); !errors.Is(err, errProduce) {
+		t.Errorf("close() error = %v, want %v", err, errProduce)
+	}
+	if err := p.err(); !errors.Is(err, errProduce) {
+		t.Errorf("err() = %v, want %v", err, errProduce)
+	}
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := newPipeline(ctx, &recordingPauser{}, 1, func(ctx context.Context, _ *kgo.Record) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	p.send(topicPartition{Topic: "orders", Partition: 0}, testBatch("orders", 0, 0, 10))
+	cancel()
+
+	if err := p.close(context.Background()); err != nil {
+		t.Errorf("close() error = %v, want nil when the mirror is stopped", err)
+	}
+}
+
+func TestPipeline_CloseTimeout(t *testing.T) {
+	p := newPipeline(context.Background(), &recordingPauser{}, 1, func(ctx context.Context, _ *kgo.Record) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	p.send(topicPartition{Topic: "orders", Partition: 0}, testBatch("orders", 0, 0, 10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var timeoutErr *TimeoutError
+	if err := p.close(ctx); !errors.As(err, &timeoutErr) {
+		t.Errorf("close() error = %v, want a *TimeoutError", err)
+	}
+}
+
+// BenchmarkMirrorPipeline compares producing fetched records from the fetch
+// loop with producing them through the per-partition pipeline, with one
+// throttled topic among several. It reports how long the records of the
+// other topics took to be produced.
+func BenchmarkMirrorPipeline(b *testing.B) {
+	const (
+		partitions = 4
+		records    = 2000
+	)
+
+	topics := []string{"throttled", "a", "b", "c"}
+	source := newFakeCluster(b, partitions, topics...)
+	for _, topic := range topics {
+		produceRecords(b, source, topic, records, kgo.NoCompression())
+	}
+	fetched := consumeRecords(b, source, records*len(topics), topics...)
+
+	// Batches are sent in fetch order, as the fetch loop would.
+	type batch struct {
+		tp      topicPartition
+		records []*kgo.Record
+	}
+	var batches []batch
+	for _, r := range fetched {
+		tp := topicPartition{Topic: r.Topic, Partition: r.Partition}
+		if len(batches) == 0 || batches[len(batches)-1].tp != tp {
+			batches = append(batches, batch{tp: tp})
+		}
+		batches[len(batches)-1].records = append(batches[len(batches)-1].records, r)
+	}
+
+	for _, parallel := range []bool{false, true} {
+		b.Run(fmt.Sprintf("parallel=%v", parallel), func(b *testing.B) {
+			sink := newFakeCluster(b, partitions, topics...)
+			client, err := kgo.NewClient(kgo.SeedBrokers(sink...))
+			if err != nil {
+				b.Fatalf("kgo.NewClient() error = %v", err)
+			}
+			defer client.Close()
+
+			// The throttled topic takes about a second per iteration.
+			throttle, err := newThrottle(ThrottleOptions{TopicMaxRecordsPerSec: map[string]float64{"throttled": records / 2}})
+			if err != nil {
+				b.Fatalf("newThrottle() error = %v", err)
+			}
+
+			var (
+				started     time.Time
+				unthrottled sync.WaitGroup
+				elapsed     time.Duration
+			)
+
+			produce := func(ctx context.Context, r *kgo.Record) error {
+				if err := throttle.wait(ctx, r); err != nil {
+					return err
+				}
+				topic := r.Topic
+				client.Produce(ctx, &kgo.Record{Topic: r.Topic, Key: r.Key, Value: r.Value}, func(*kgo.Record, error) {
+					if topic != "throttled" {
+						unthrottled.Done()
+					}
+				})
+				return nil
+			}
+
+			ctx := context.Background()
+			for b.Loop() {
+				started = time.Now()
+				unthrottled.Add(records * (len(topics) - 1))
+				done := make(chan struct{})
+				go func() {
+					unthrottled.Wait()
+					elapsed += time.Since(started)
+					close(done)
+				}()
+
+				if parallel {
+					p := newPipeline(ctx, &recordingPauser{}, 4, produce)
+					for _, batch := range batches {
+						p.send(batch.tp, batch.records)
+					}
+					if err := p.close(context.Background()); err != nil {
+						b.Fatalf("close() error = %v", err)
+					}
+				} else {
+					for _, batch := range batches {
+						for _, r := range batch.records {
+							if err := produce(ctx, r); err != nil {
+								b.Fatalf("produce() error = %v", err)
+							}
+						}
+					}
+				}
+
+				if err := client.Flush(ctx); err != nil {
+					b.Fatalf("Flush() error = %v", err)
+				}
+				<-done
+			}
+
+			b.ReportMetric(float64(elapsed.Milliseconds())/float64(b.N), "unthrottled-ms/op")
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// mirrorPlan describes everything a mirror is going to do before
+// it starts consuming records.
+type mirrorPlan struct {
+	Delete []string       `json:"delete"`
+	Create []plannedTopic `json:"create"`
+	// ACLs is nil unless ACLs are mirrored.
+	ACLs []plannedACL `json:"acls,omitempty"`
+}
+
+// plannedTopic is a sink topic that is going to be created and mirrored.
+// Sink is the name of the sink topic if it differs from the source topic.
+type plannedTopic struct {
+	Topic             string             `json:"topic"`
+	Sink              string             `json:"sink,omitempty"`
+	Partitions        int32              `json:"partitions"`
+	ReplicationFactor int16              `json:"replication_factor"`
+	Configs           map[string]*string `json:"configs,omitempty"`
+	// Existing is set for a sink topic that exists and is kept, with
+	// Options.KeepSinkTopics or when resuming from checkpoints.
+	Existing bool `json:"existing,omitempty"`
+	// Routing is how records are routed to sink partitions when their
+	// number differs from the source, empty if records keep their source
+	// partition.
+	Routing string             `json:"routing,omitempty"`
+	Offsets []plannedPartition `json:"offsets"`
+}
+
+// plannedPartition is a source partition that is going to be mirrored.
+// HighWatermark is its last stable offset when the source only reads
+// committed records, as those of an open transaction can't be read yet.
+type plannedPartition struct {
+	Partition     int32 `json:"partition"`
+	StartOffset   int64 `json:"start_offset"`
+	HighWatermark int64 `json:"high_watermark"`
+	Records       int64 `json:"records"`
+}
+
+// sinkTopic returns the name of the sink topic.
+func (pt plannedTopic) sinkTopic() string {
+	if pt.Sink == "" {
+		return pt.Topic
+	}
+	return pt.Sink
+}
+
+// Records returns the estimated number of records to copy for the topic.
+func (pt plannedTopic) Records() int64 {
+	var total int64
+	for _, p := range pt.Offsets {
+		total += p.Records
+	}
+	return total
+}
+
+// buildPlan plans the mirror of the topics. The partitions of resumed, the
+// checkpoints of an exactly-once mirror, start at their checkpoint, and the
+// sink topics of their topics are kept. The partitions of a committed source
+// end at their last stable offset rather than their high watermark.
+func (m *Mirror) buildPlan(rootCtx context.Context, sourceClient, sinkClient *kadm.Client, sourceTopics, sinkTopics kadm.TopicDetails, resumed map[topicPartition]int64, committed bool) (mirrorPlan, error) {
+	plan := mirrorPlan{
+		Delete: make([]string, 0),
+		Create: make([]plannedTopic, 0, len(m.names)),
+	}
+
+	// The sink topics of resumed topics hold the records mirrored so far.
+	resumedTopics := map[string]bool{}
+	for tp := range resumed {
+		resumedTopics[tp.Topic] = true
+	}
+	keep := func(topic string) bool {
+		return m.opts.KeepSinkTopics || resumedTopics[topic]
+	}
+
+	for _, topic := range m.names {
+		if sinkTopic := m.sinkTopic(topic); sinkTopics.Has(sinkTopic) && !keep(topic) {
+			plan.Delete = append(plan.Delete, sinkTopic)
+		}
+	}
+
+	watermarks, err := m.getWatermarks(rootCtx, sourceClient, committed, m.names...)
+	if err != nil {
+		return plan, fmt.Errorf("failed to get source watermarks: %w", err)
+	}
+
+	for _, topic := range m.names {
+		dt := sourceTopics[topic]
+
+		numPartitions := len(dt.Partitions)
+		if numPartitions > int(math.MaxInt32) {
+			return plan, fmt.Errorf("number of partitions %d of topic %q exceeds maximum int32 value", numPartitions, topic)
+		}
+
+		pt := plannedTopic{
+			Topic:             topic,
+			Sink:              m.renamed[topic],
+			Partitions:        int32(numPartitions), // #nosec G115
+			ReplicationFactor: -1,
+			Offsets:           make([]plannedPartition, 0, numPartitions),
+		}
+
+		partitions, ok := m.opts.SinkPartitions[topic]
+		if keep(topic) && sinkTopics.Has(pt.sinkTopic()) {
+			pt.Existing = true
+			partitions, ok = int32(len(sinkTopics[pt.sinkTopic()].Partitions)), true // #nosec G115
+		}
+		if ok && partitions != pt.Partitions {
+			pt.Partitions = partitions
+			pt.Routing = m.opts.PartitionRouting
+			sideLogger(sideSink).Warn("Sink topic has a different number of partitions than the source, records aren't ordered as on the source",
+				slog.String("topic", topic),
+				slog.Int("source_partitions", numPartitions),
+				slog.Int("sink_partitions", int(partitions)),
+				slog.String("routing", pt.Routing),
+			)
+		}
+
+		for _, partition := range dt.Partitions.Numbers() {
+			offset, ok := m.topics[topic].OffsetOf(partition)
+			if !ok {
+				continue
+			}
+
+			wm := watermarks[topic][partition]
+			if next, ok := resumed[topicPartition{Topic: topic, Partition: partition}]; ok {
+				offset = next
+			}
+			start := min(max(wm.Resolve(offset), wm.Start), wm.End)
+			pt.Offsets = append(pt.Offsets, plannedPartition{
+				Partition:     partition,
+				StartOffset:   start,
+				HighWatermark: wm.End,
+				Records:       wm.End - start,
+			})
+		}
+		slices.SortFunc(pt.Offsets, func(a, b plannedPartition) int { return int(a.Partition - b.Partition) })
+
+		plan.Create = append(plan.Create, pt)
+	}
+
+	if err := m.planReplication(rootCtx, sourceClient, sinkClient, sourceTopics, &plan); err != nil {
+		return plan, err
+	}
+
+	if m.opts.ACLs {
+		plan.ACLs, err = m.describeACLs(rootCtx, sourceClient)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	return plan, nil
+}
+
+func printPlan(w io.Writer, plan mirrorPlan, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "Topics to delete on sink:")
+	if len(plan.Delete) == 0 {
+		_, _ = fmt.Fprintln(tw, "  (none)")
+	}
+	for _, topic := range plan.Delete {
+		_, _ = fmt.Fprintf(tw, "  %s\n", topic)
+	}
+
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "Topics to create on sink:")
+	_, _ = fmt.Fprintln(tw, "  TOPIC\tPARTITIONS\tREPLICATION\tCONFIGS\tRECORDS\tROUTING")
+	for _, pt := range plan.Create {
+		_, _ = fmt.Fprintf(tw, "  %s\t%d\t%s\t%d\t%d\t%s\n", topicString(pt), pt.Partitions, replicationFactorString(pt.ReplicationFactor), len(pt.Configs), pt.Records(), routingString(pt.Routing))
+	}
+
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "Start offsets:")
+	_, _ = fmt.Fprintln(tw, "  TOPIC\tPARTITION\tSTART\tHIGH-WATERMARK\tRECORDS")
+	for _, pt := range plan.Create {
+		for _, p := range pt.Offsets {
+			_, _ = fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\n", pt.Topic, p.Partition, p.StartOffset, p.HighWatermark, p.Records)
+		}
+	}
+
+	if plan.ACLs != nil {
+		_, _ = fmt.Fprintln(tw)
+		_, _ = fmt.Fprintln(tw, "ACLs to create on sink:")
+		if len(plan.ACLs) == 0 {
+			_, _ = fmt.Fprintln(tw, "  (none)")
+		} else {
+			_, _ = fmt.Fprintln(tw, "  TYPE\tNAME\tPATTERN\tPRINCIPAL\tHOST\tOPERATION\tPERMISSION")
+		}
+		for _, acl := range plan.ACLs {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", acl.Type, acl.Name, acl.Pattern, acl.Principal, acl.Host, acl.Operation, acl.Permission)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// printSinkPlans writes the plans of several sinks: a JSON object of the
+// plans by sink, or the table of every plan under the name of its sink.
+func printSinkPlans(w io.Writer, targets []*sinkTarget, format string) error {
+	if format == "json" {
+		plans := make(map[string]mirrorPlan, len(targets))
+		for _, t := range targets {
+			plans[t.name] = t.plan
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	}
+
+	for i, t := range targets {
+		if i > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "Sink %s:\n\n", t.name)
+		if err := printPlan(w, t.plan, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePlans returns the plan of the source side of a mirror to several
+// sinks, with every topic of any sink once.
+func mergePlans(targets []*sinkTarget) mirrorPlan {
+	if len(targets) == 1 {
+		return targets[0].plan
+	}
+
+	var plan mirrorPlan
+	seen := map[string]bool{}
+	for _, t := range targets {
+		for _, pt := range t.plan.Create {
+			if !seen[pt.Topic] {
+				seen[pt.Topic] = true
+				plan.Create = append(plan.Create, pt)
+			}
+		}
+	}
+	return plan
+}
+
+func topicString(pt plannedTopic) string {
+	name := pt.Topic
+	if pt.Sink != "" {
+		name += " -> " + pt.Sink
+	}
+	if pt.Existing {
+		name += " (existing)"
+	}
+	return name
+}
+
+func routingString(routing string) string {
+	if routing == "" {
+		return "source"
+	}
+	return routing
+}
+
+func replicationFactorString(rf int16) string {
+	if rf < 0 {
+		return "default"
+	}
+	return fmt.Sprint(rf)
+}
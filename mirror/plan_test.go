@@ -0,0 +1,184 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func testPlan() mirrorPlan {
+	return mirrorPlan{
+		Delete: []string{"orders"},
+		Create: []plannedTopic{
+			{
+				Topic:             "orders",
+				Partitions:        2,
+				ReplicationFactor: -1,
+				Offsets: []plannedPartition{
+					{Partition: 0, StartOffset: 10, HighWatermark: 30, Records: 20},
+					{Partition: 1, StartOffset: 0, HighWatermark: 5, Records: 5},
+				},
+			},
+		},
+	}
+}
+
+func TestPlannedTopic_Records(t *testing.T) {
+	if got := testPlan().Create[0].Records(); got != 25 {
+		t.Errorf("Records() = %d, want 25", got)
+	}
+}
+
+func TestPrintPlan_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printPlan(&buf, testPlan(), "json"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+
+	var got mirrorPlan
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("printPlan() produced invalid JSON: %v", err)
+	}
+	if len(got.Delete) != 1 || got.Delete[0] != "orders" {
+		t.Errorf("printPlan() Delete = %v, want [orders]", got.Delete)
+	}
+	if len(got.Create) != 1 || len(got.Create[0].Offsets) != 2 || got.Create[0].Offsets[0].StartOffset != 10 {
+		t.Errorf("printPlan() Create = %+v", got.Create)
+	}
+}
+
+func TestPrintPlan_Table(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printPlan(&buf, testPlan(), "table"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Topics to delete on sink:", "RECORDS  ROUTING", "orders  2           default      0        25", "orders  0          10     30              20"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("printPlan() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintPlan_TableExisting(t *testing.T) {
+	plan := testPlan()
+	plan.Delete = nil
+	plan.Create[0].Sink = "eu.orders"
+	plan.Create[0].Existing = true
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, plan, "table"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "orders -> eu.orders (existing)") {
+		t.Errorf("printPlan() output missing the existing sink topic:\n%s", buf.String())
+	}
+}
+
+func TestPrintPlan_TableNothingToDelete(t *testing.T) {
+	plan := testPlan()
+	plan.Delete = nil
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, plan, "table"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "(none)") {
+		t.Errorf("printPlan() output missing (none):\n%s", buf.String())
+	}
+}
+
+func TestPrintPlan_ACLs(t *testing.T) {
+	plan := testPlan()
+	plan.ACLs = []plannedACL{{
+		Principal:  "User:orders-local",
+		Host:       "*",
+		Type:       kmsg.ACLResourceTypeTopic,
+		Name:       "orders",
+		Pattern:    kadm.ACLPatternLiteral,
+		Operation:  kadm.OpRead,
+		Permission: kmsg.ACLPermissionTypeAllow,
+	}}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, plan, "table"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+	for _, want := range []string{"ACLs to create on sink:", "TOPIC", "User:orders-local", "READ", "ALLOW"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printPlan() output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := printPlan(&buf, plan, "json"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+	var got struct {
+		ACLs []map[string]string `json:"acls"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("printPlan() produced invalid JSON: %v", err)
+	}
+	if len(got.ACLs) != 1 || got.ACLs[0]["operation"] != "READ" || got.ACLs[0]["principal"] != "User:orders-local" {
+		t.Errorf("printPlan() acls = %v", got.ACLs)
+	}
+
+	// With ACLs mirrored but none found, the section says so.
+	plan.ACLs = []plannedACL{}
+	buf.Reset()
+	if err := printPlan(&buf, plan, "table"); err != nil {
+		t.Fatalf("printPlan() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "ACLs to create on sink:\n  (none)") {
+		t.Errorf("printPlan() output missing empty ACLs:\n%s", buf.String())
+	}
+}
+
+func TestPrintSinkPlans(t *testing.T) {
+	targets := []*sinkTarget{
+		{sinkMirror: sinkMirror{name: "alice"}, plan: testPlan()},
+		{sinkMirror: sinkMirror{name: "bob"}, plan: mirrorPlan{Delete: []string{}, Create: []plannedTopic{}}},
+	}
+
+	var buf bytes.Buffer
+	if err := printSinkPlans(&buf, targets, "json"); err != nil {
+		t.Fatalf("printSinkPlans() error = %v", err)
+	}
+	var got map[string]mirrorPlan
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("printSinkPlans() produced invalid JSON: %v", err)
+	}
+	if len(got) != 2 || len(got["alice"].Create) != 1 || len(got["bob"].Create) != 0 {
+		t.Errorf("printSinkPlans() = %+v, want the plans of alice and bob", got)
+	}
+
+	buf.Reset()
+	if err := printSinkPlans(&buf, targets, "table"); err != nil {
+		t.Fatalf("printSinkPlans() error = %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "Sink alice:") || !strings.Contains(out, "Sink bob:") {
+		t.Errorf("printSinkPlans() table doesn't name every sink:\n%s", out)
+	}
+}
+
+func TestMergePlans(t *testing.T) {
+	users := plannedTopic{Topic: "users", Partitions: 1}
+	targets := []*sinkTarget{
+		{plan: testPlan()},
+		{plan: mirrorPlan{Create: []plannedTopic{users, testPlan().Create[0]}}},
+	}
+
+	got := mergePlans(targets)
+	if len(got.Create) != 2 || got.Create[0].Topic != "orders" || got.Create[1].Topic != "users" {
+		t.Errorf("mergePlans() = %+v, want orders and users once", got.Create)
+	}
+}
@@ -0,0 +1,152 @@
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// rateSmoothing is the weight of the newest sample in the exponentially
+// weighted throughput average.
+const rateSmoothing = 0.3
+
+// topicPartition identifies a partition of a topic.
+type topicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// PartitionProgress is the mirroring progress of a single partition.
+type PartitionProgress struct {
+	Topic     string
+	Partition int32
+
+	Start         int64
+	Offset        int64
+	HighWatermark int64
+
+	// Rate is the smoothed throughput in records per second.
+	Rate         float64
+	sampled      bool
+	sampleOffset int64
+	sampleTime   time.Time
+}
+
+// Percent returns how much of the partition, up to its current high
+// watermark, has been mirrored.
+func (pp PartitionProgress) Percent() float64 {
+	total := pp.HighWatermark - pp.Start
+	if total <= 0 {
+		return 100
+	}
+	return min(float64(pp.Offset-pp.Start)/float64(total)*100, 100)
+}
+
+// ETA returns the estimated time to reach the high watermark, or -1 if it
+// can't be estimated.
+func (pp PartitionProgress) ETA() time.Duration {
+	remaining := pp.HighWatermark - pp.Offset
+	if remaining <= 0 {
+		return 0
+	}
+	if pp.Rate <= 0 {
+		return -1
+	}
+	return time.Duration(float64(remaining) / pp.Rate * float64(time.Second))
+}
+
+// progressTracker follows the mirroring progress of every planned partition.
+// A nil *progressTracker is valid and tracks nothing.
+type progressTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*PartitionProgress
+}
+
+func newProgressTracker(plan mirrorPlan, now time.Time) *progressTracker {
+	pt := &progressTracker{partitions: map[topicPartition]*PartitionProgress{}}
+	for _, topic := range plan.Create {
+		for _, p := range topic.Offsets {
+			pt.partitions[topicPartition{Topic: topic.Topic, Partition: p.Partition}] = &PartitionProgress{
+				Topic:         topic.Topic,
+				Partition:     p.Partition,
+				Start:         p.StartOffset,
+				Offset:        p.StartOffset,
+				HighWatermark: p.HighWatermark,
+				sampleOffset:  p.StartOffset,
+				sampleTime:    now,
+			}
+		}
+	}
+	return pt
+}
+
+func (pt *progressTracker) observe(p kgo.FetchTopicPartition) {
+	if pt == nil || len(p.Records) == 0 {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pp, ok := pt.partitions[topicPartition{Topic: p.Topic, Partition: p.Partition}]
+	if !ok {
+		return
+	}
+	pp.Offset = p.Records[len(p.Records)-1].Offset + 1
+	pp.HighWatermark = max(pp.HighWatermark, p.HighWatermark)
+}
+
+// snapshot updates the throughput of every partition and returns a copy of
+// the progress, sorted by topic and partition.
+func (pt *progressTracker) snapshot(now time.Time) []PartitionProgress {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	keys := make([]topicPartition, 0, len(pt.partitions))
+	for key, pp := range pt.partitions {
+		keys = append(keys, key)
+
+		elapsed := now.Sub(pp.sampleTime).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rate := float64(pp.Offset-pp.sampleOffset) / elapsed
+		if pp.sampled {
+			pp.Rate = rateSmoothing*rate + (1-rateSmoothing)*pp.Rate
+		} else {
+			pp.Rate = rate
+			pp.sampled = true
+		}
+		pp.sampleOffset = pp.Offset
+		pp.sampleTime = now
+	}
+
+	slices.SortFunc(keys, func(a, b topicPartition) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+
+	progress := make([]PartitionProgress, 0, len(keys))
+	for _, key := range keys {
+		progress = append(progress, *pt.partitions[key])
+	}
+	return progress
+}
+
+// run reports the progress every interval until ctx is done.
+func (pt *progressTracker) run(ctx context.Context, interval time.Duration, report func([]PartitionProgress)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			report(pt.snapshot(now))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mirror
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestPartitionProgress_Percent(t *testing.T) {
+	tests := []struct {
+		name string
+		pp   PartitionProgress
+		want float64
+	}{
+		{"not started", PartitionProgress{Start: 100, Offset: 100, HighWatermark: 200}, 0},
+		{"half way", PartitionProgress{Start: 100, Offset: 150, HighWatermark: 200}, 50},
+		{"done", PartitionProgress{Start: 100, Offset: 200, HighWatermark: 200}, 100},
+		{"nothing to copy", PartitionProgress{Start: 200, Offset: 200, HighWatermark: 200}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pp.Percent(); got != tt.want {
+				t.Errorf("Percent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionProgress_ETA(t *testing.T) {
+	tests := []struct {
+		name string
+		pp   PartitionProgress
+		want time.Duration
+	}{
+		{"caught up", PartitionProgress{Offset: 10, HighWatermark: 10}, 0},
+		{"unknown rate", PartitionProgress{Offset: 0, HighWatermark: 10}, -1},
+		{"estimated", PartitionProgress{Offset: 0, HighWatermark: 100, Rate: 10}, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pp.ETA(); got != tt.want {
+				t.Errorf("ETA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	started := time.Unix(1000, 0)
+	plan := mirrorPlan{Create: []plannedTopic{{
+		Topic: "orders",
+		Offsets: []plannedPartition{
+			{Partition: 1, StartOffset: 0, HighWatermark: 100},
+			{Partition: 0, StartOffset: 50, HighWatermark: 150},
+		},
+	}}}
+
+	pt := newProgressTracker(plan, started)
+	pt.observe(kgo.FetchTopicPartition{
+		Topic: "orders",
+		FetchPartition: kgo.FetchPartition{
+			Partition:     1,
+			HighWatermark: 120,
+			Records:       []*kgo.Record{{Offset: 18}, {Offset: 19}},
+		},
+	})
+	// Partitions that are not mirrored are ignored.
+	pt.observe(kgo.FetchTopicPartition{
+		Topic:          "users",
+		FetchPartition: kgo.FetchPartition{Records: []*kgo.Record{{Offset: 1}}},
+	})
+
+	progress := pt.snapshot(started.Add(2 * time.Second))
+	if len(progress) != 2 || progress[0].Partition != 0 || progress[1].Partition != 1 {
+		t.Fatalf("snapshot() = %v, want partitions 0 and 1", progress)
+	}
+
+	p1 := progress[1]
+	if p1.Offset != 20 || p1.HighWatermark != 120 {
+		t.Errorf("snapshot() partition 1 offset/high watermark = %d/%d, want 20/120", p1.Offset, p1.HighWatermark)
+	}
+	if p1.Rate != 10 {
+		t.Errorf("snapshot() partition 1 rate = %v, want 10", p1.Rate)
+	}
+	if progress[0].Rate != 0 {
+		t.Errorf("snapshot() partition 0 rate = %v, want 0", progress[0].Rate)
+	}
+}
+
+func TestProgressTracker_Nil(t *testing.T) {
+	var pt *progressTracker
+	pt.observe(kgo.FetchTopicPartition{FetchPartition: kgo.FetchPartition{Records: []*kgo.Record{{}}}})
+}
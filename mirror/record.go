@@ -0,0 +1,67 @@
+package mirror
+
+import (
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// CapturedRecord is the JSON representation of a record written by the
+// capture command and read by the replay command, one record per line. The
+// disk store of the latest-per-key mode uses it too.
+type CapturedRecord struct {
+	Topic     string           `json:"topic"`
+	Partition int32            `json:"partition"`
+	Offset    int64            `json:"offset"`
+	Timestamp time.Time        `json:"timestamp"`
+	Key       []byte           `json:"key"`
+	Value     []byte           `json:"value"`
+	Headers   []CapturedHeader `json:"headers,omitempty"`
+}
+
+// CapturedHeader is the JSON representation of a record header.
+type CapturedHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// NewCapturedRecord returns the JSON representation of r.
+func NewCapturedRecord(r *kgo.Record) CapturedRecord {
+	out := CapturedRecord{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       r.Key,
+		Value:     r.Value,
+	}
+	for _, h := range r.Headers {
+		out.Headers = append(out.Headers, CapturedHeader{Key: h.Key, Value: h.Value})
+	}
+	return out
+}
+
+// Record returns the record to produce. Its offset is left to the sink.
+func (cr CapturedRecord) Record() *kgo.Record {
+	r := &kgo.Record{
+		Topic:     cr.Topic,
+		Partition: cr.Partition,
+		Timestamp: cr.Timestamp,
+		Key:       cr.Key,
+		Value:     cr.Value,
+	}
+	for _, h := range cr.Headers {
+		r.Headers = append(r.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return r
+}
+
+// hasOrigin reports whether r has a ProvenanceHeader of origin.
+func hasOrigin(r *kgo.Record, origin string) bool {
+	for _, h := range r.Headers {
+		if h.Key == ProvenanceHeader && string(h.Value) == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestCapturedRecord_RoundTrip(t *testing.T) {
+	original := &kgo.Record{
+		Topic:     "orders",
+		Partition: 3,
+		Offset:    42,
+		Timestamp: time.UnixMilli(1700000000000).UTC(),
+		Key:       []byte("key"),
+		Value:     []byte{0x00, 0xff, 0x10},
+		Headers:   []kgo.RecordHeader{{Key: "trace", Value: []byte("abc")}},
+	}
+
+	data, err := json.Marshal(NewCapturedRecord(original))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var decoded CapturedRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	got := decoded.Record()
+	if got.Topic != original.Topic || got.Partition != original.Partition {
+		t.Errorf("Record() topic/partition = %s/%d, want %s/%d", got.Topic, got.Partition, original.Topic, original.Partition)
+	}
+	if !got.Timestamp.Equal(original.Timestamp) {
+		t.Errorf("Record() Timestamp = %v, want %v", got.Timestamp, original.Timestamp)
+	}
+	if !bytes.Equal(got.Key, original.Key) || !bytes.Equal(got.Value, original.Value) {
+		t.Errorf("Record() key/value = %q/%q, want %q/%q", got.Key, got.Value, original.Key, original.Value)
+	}
+	if len(got.Headers) != 1 || got.Headers[0].Key != "trace" || string(got.Headers[0].Value) != "abc" {
+		t.Errorf("Record() Headers = %v, want %v", got.Headers, original.Headers)
+	}
+}
+
+func TestCapturedRecord_Tombstone(t *testing.T) {
+	data, err := json.Marshal(NewCapturedRecord(&kgo.Record{Topic: "orders", Key: []byte("k")}))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var decoded CapturedRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if decoded.Record().Value != nil {
+		t.Errorf("Record() Value = %v, want nil", decoded.Value)
+	}
+}
+
+func TestHasOrigin(t *testing.T) {
+	r := &kgo.Record{Headers: []kgo.RecordHeader{
+		{Key: "trace", Value: []byte("dev")},
+		{Key: ProvenanceHeader, Value: []byte("local")},
+		{Key: ProvenanceHeader, Value: []byte("dev")},
+	}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "local", want: true},
+		{origin: "dev", want: true},
+		{origin: "staging", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := hasOrigin(r, tt.origin); got != tt.want {
+				t.Errorf("hasOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const minInsyncReplicasConfig = "min.insync.replicas"
+
+// replicationConfigured reports whether the replication of the sink topics
+// is set, rather than left to the defaults of the sink brokers.
+func (m *Mirror) replicationConfigured() bool {
+	return m.opts.ReplicationFactor > 0 || m.opts.SourceReplicationFactor || m.opts.MinInsyncReplicas > 0
+}
+
+// planReplication sets the replication factor and min.insync.replicas of the
+// planned sink topics.
+func (m *Mirror) planReplication(rootCtx context.Context, sourceClient, sinkClient *kadm.Client, sourceTopics kadm.TopicDetails, plan *mirrorPlan) error {
+	if !m.replicationConfigured() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	brokers, err := sinkClient.ListBrokers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list sink brokers: %w", err)
+	}
+
+	// The source min.insync.replicas is only needed when it isn't given.
+	var configs kadm.ResourceConfigs
+	if m.opts.MinInsyncReplicas <= 0 {
+		configs, err = sourceClient.DescribeTopicConfigs(ctx, m.names...)
+		if err != nil {
+			return fmt.Errorf("failed to describe source topic configs: %w", err)
+		}
+		for _, config := range configs {
+			if config.Err != nil {
+				return fmt.Errorf("failed to describe configs of source topic %q: %w", config.Name, config.Err)
+			}
+		}
+	}
+
+	for i := range plan.Create {
+		pt := &plan.Create[i]
+		if pt.Existing {
+			continue
+		}
+
+		sourceMinInsync := 0
+		if config, err := configs.On(pt.Topic, nil); err == nil {
+			sourceMinInsync = topicMinInsyncReplicas(config)
+		}
+
+		replicationFactor, minInsync := m.sinkReplication(pt.Topic, sourceTopics[pt.Topic].Partitions.NumReplicas(), sourceMinInsync, len(brokers))
+		pt.ReplicationFactor = replicationFactor
+		if minInsync > 0 {
+			if pt.Configs == nil {
+				pt.Configs = map[string]*string{}
+			}
+			value := strconv.Itoa(int(minInsync))
+			pt.Configs[minInsyncReplicasConfig] = &value
+		}
+	}
+	return nil
+}
+
+// topicMinInsyncReplicas returns the min.insync.replicas set on the topic of
+// config, 0 if it isn't. A default of the source brokers isn't copied: the
+// sink topic gets the default of the sink brokers instead.
+func topicMinInsyncReplicas(config kadm.ResourceConfig) int {
+	for _, c := range config.Configs {
+		if c.Key == minInsyncReplicasConfig && c.Source == kmsg.ConfigSourceDynamicTopicConfig {
+			minInsync, _ := strconv.Atoi(c.MaybeValue())
+			return minInsync
+		}
+	}
+	return 0
+}
+
+// sinkReplication returns the replication factor of a sink topic, -1 for the
+// default of the sink brokers, and its min.insync.replicas, 0 to leave it
+// unset. The replication factor is clamped to the number of sink brokers,
+// so a small local cluster can host topics of a bigger one, and
+// min.insync.replicas to the replication factor, so produces requiring
+// acknowledgements from all replicas don't fail.
+func (m *Mirror) sinkReplication(topic string, sourceReplicas, sourceMinInsync, sinkBrokers int) (int16, int16) {
+	replicationFactor := int(m.opts.ReplicationFactor)
+	if m.opts.SourceReplicationFactor {
+		replicationFactor = sourceReplicas
+	}
+	if replicationFactor <= 0 {
+		replicationFactor = -1
+	}
+
+	if replicationFactor > sinkBrokers && sinkBrokers > 0 {
+		sideLogger(sideSink).Warn("Clamping replication factor to the number of sink brokers",
+			slog.String("topic", topic),
+			slog.Int("replication_factor", replicationFactor),
+			slog.Int("brokers", sinkBrokers),
+		)
+		replicationFactor = sinkBrokers
+	}
+
+	minInsync := int(m.opts.MinInsyncReplicas)
+	if minInsync <= 0 && replicationFactor > 0 {
+		minInsync = sourceMinInsync
+	}
+
+	if replicationFactor > 0 && minInsync > replicationFactor {
+		sideLogger(sideSink).Warn("Clamping min.insync.replicas to the replication factor",
+			slog.String("topic", topic),
+			slog.Int("min_insync_replicas", minInsync),
+			slog.Int("replication_factor", replicationFactor),
+		)
+		minInsync = replicationFactor
+	}
+
+	return int16(replicationFactor), int16(max(minInsync, 0)) // #nosec G115
+}
@@ -0,0 +1,123 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestSinkReplication(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            Options
+		sourceReplicas  int
+		sourceMinInsync int
+		sinkBrokers     int
+		wantReplication int16
+		wantMinInsync   int16
+	}{
+		{
+			name:            "sink default",
+			opts:            Options{},
+			sourceReplicas:  3,
+			sourceMinInsync: 2,
+			sinkBrokers:     3,
+			wantReplication: -1,
+			wantMinInsync:   0,
+		},
+		{
+			name:            "explicit",
+			opts:            Options{ReplicationFactor: 2},
+			sourceReplicas:  3,
+			sourceMinInsync: 2,
+			sinkBrokers:     3,
+			wantReplication: 2,
+			wantMinInsync:   2,
+		},
+		{
+			name:            "source",
+			opts:            Options{SourceReplicationFactor: true},
+			sourceReplicas:  3,
+			sourceMinInsync: 2,
+			sinkBrokers:     3,
+			wantReplication: 3,
+			wantMinInsync:   2,
+		},
+		{
+			name:            "clamped to brokers",
+			opts:            Options{SourceReplicationFactor: true},
+			sourceReplicas:  3,
+			sourceMinInsync: 2,
+			sinkBrokers:     1,
+			wantReplication: 1,
+			wantMinInsync:   1,
+		},
+		{
+			name:            "explicit min insync clamped",
+			opts:            Options{ReplicationFactor: 2, MinInsyncReplicas: 3},
+			sourceReplicas:  1,
+			sourceMinInsync: 1,
+			sinkBrokers:     3,
+			wantReplication: 2,
+			wantMinInsync:   2,
+		},
+		{
+			name:            "explicit min insync with sink default",
+			opts:            Options{MinInsyncReplicas: 2},
+			sourceReplicas:  3,
+			sourceMinInsync: 1,
+			sinkBrokers:     3,
+			wantReplication: -1,
+			wantMinInsync:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mirror{opts: tt.opts}
+			replication, minInsync := m.sinkReplication("orders", tt.sourceReplicas, tt.sourceMinInsync, tt.sinkBrokers)
+			if replication != tt.wantReplication || minInsync != tt.wantMinInsync {
+				t.Errorf("sinkReplication() = %d, %d, want %d, %d", replication, minInsync, tt.wantReplication, tt.wantMinInsync)
+			}
+		})
+	}
+}
+
+func TestTopicMinInsyncReplicas(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		configs []kadm.Config
+		want    int
+	}{
+		{
+			name: "set on the topic",
+			configs: []kadm.Config{
+				{Key: "retention.ms", Value: value("1000"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+				{Key: minInsyncReplicasConfig, Value: value("2"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+			},
+			want: 2,
+		},
+		{
+			name:    "broker default",
+			configs: []kadm.Config{{Key: minInsyncReplicasConfig, Value: value("2"), Source: kmsg.ConfigSourceDefaultConfig}},
+		},
+		{
+			name:    "static broker config",
+			configs: []kadm.Config{{Key: minInsyncReplicasConfig, Value: value("2"), Source: kmsg.ConfigSourceStaticBrokerConfig}},
+		},
+		{
+			name: "unset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topicMinInsyncReplicas(kadm.ResourceConfig{Name: "orders", Configs: tt.configs}); got != tt.want {
+				t.Errorf("topicMinInsyncReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// router picks the sink partition of the records of topics created with a
+// different number of partitions than on the source. Records of other topics
+// keep their source partition. A nil *router routes nothing.
+type router struct {
+	topics map[string]*routedTopic
+}
+
+type routedTopic struct {
+	partitions int32
+	routing    string
+	// next is the round-robin counter, shared by the partition workers.
+	next atomic.Uint32
+}
+
+var kafkaHasher = kgo.KafkaHasher(murmur2)
+
+func newRouter(plan mirrorPlan) *router {
+	topics := map[string]*routedTopic{}
+	for _, pt := range plan.Create {
+		if pt.Routing != "" {
+			topics[pt.Topic] = &routedTopic{partitions: pt.Partitions, routing: pt.Routing}
+		}
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+	return &router{topics: topics}
+}
+
+// route sets the sink partition of r, which holds its source partition.
+func (rt *router) route(r *kgo.Record) {
+	if rt == nil {
+		return
+	}
+
+	topic, ok := rt.topics[r.Topic]
+	if !ok {
+		return
+	}
+
+	switch {
+	case topic.routing == "murmur2" && r.Key != nil:
+		r.Partition = int32(kafkaHasher(r.Key, int(topic.partitions))) // #nosec G115
+	case topic.routing == "modulo":
+		r.Partition %= topic.partitions
+	default:
+		// Records without a key can't be hashed, so they are spread
+		// round-robin like records of the round-robin routing.
+		r.Partition = int32((topic.next.Add(1) - 1) % uint32(topic.partitions)) // #nosec G115
+	}
+}
+
+// murmur2 is the hash of the default partitioner of the Kafka Java client,
+// so keys hashed with it land in the partition a Kafka producer would pick.
+func murmur2(b []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	h := seed ^ uint32(len(b)) // #nosec G115
+	for len(b) >= 4 {
+		k := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	switch len(b) {
+	case 3:
+		h ^= uint32(b[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(b[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(b[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
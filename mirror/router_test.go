@@ -0,0 +1,91 @@
+package mirror
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestMurmur2(t *testing.T) {
+	// Test vectors of the Kafka Java client.
+	tests := []struct {
+		key  string
+		want int32
+	}{
+		{"21", -973932308},
+		{"foobar", -790332482},
+		{"a-little-bit-long-string", -985981536},
+		{"a-little-bit-longer-string", -1486304829},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+		{"abc", 479470107},
+	}
+
+	for _, tt := range tests {
+		if got := int32(murmur2([]byte(tt.key))); got != tt.want {
+			t.Errorf("murmur2(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	plan := mirrorPlan{Create: []plannedTopic{
+		{Topic: "orders", Partitions: 3, Routing: "modulo"},
+		{Topic: "users", Partitions: 3, Routing: "murmur2"},
+		{Topic: "clicks", Partitions: 3, Routing: "round-robin"},
+		{Topic: "payments", Partitions: 8},
+	}}
+	rt := newRouter(plan)
+
+	route := func(topic string, partition int32, key []byte) int32 {
+		r := &kgo.Record{Topic: topic, Partition: partition, Key: key}
+		rt.route(r)
+		return r.Partition
+	}
+
+	for source, want := range []int32{0, 1, 2, 0, 1, 2, 0, 1} {
+		if got := route("orders", int32(source), nil); got != want {
+			t.Errorf("route(orders, %d) = %d, want %d", source, got, want)
+		}
+	}
+
+	if got := route("payments", 7, nil); got != 7 {
+		t.Errorf("route(payments, 7) = %d, want the source partition", got)
+	}
+
+	// Keys land where the default partitioner of a Kafka producer puts them.
+	partitioner := kgo.StickyKeyPartitioner(nil).ForTopic("users")
+	for i := range 20 {
+		key := []byte(fmt.Sprintf("user-%d", i))
+		want := int32(partitioner.Partition(&kgo.Record{Key: key}, 3))
+		if got := route("users", 5, key); got != want {
+			t.Errorf("route(users, %q) = %d, want %d", key, got, want)
+		}
+	}
+
+	for i, want := range []int32{0, 1, 2, 0, 1} {
+		if got := route("clicks", 4, []byte("same-key")); got != want {
+			t.Errorf("route(clicks) #%d = %d, want %d", i, got, want)
+		}
+	}
+
+	// Records without a key can't be hashed and are spread round-robin.
+	for i, want := range []int32{0, 1, 2} {
+		if got := route("users", 0, nil); got != want {
+			t.Errorf("route(users) without key #%d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestRouter_Nil(t *testing.T) {
+	rt := newRouter(mirrorPlan{Create: []plannedTopic{{Topic: "orders", Partitions: 8}}})
+	if rt != nil {
+		t.Fatalf("newRouter() without routed topics = %+v, want nil", rt)
+	}
+
+	r := &kgo.Record{Topic: "orders", Partition: 5}
+	rt.route(r)
+	if r.Partition != 5 {
+		t.Errorf("route() of a nil router changed the partition to %d", r.Partition)
+	}
+}
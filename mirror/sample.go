@@ -0,0 +1,167 @@
+package mirror
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// sampleAllTopics is the topic name of a sampling rule that applies to every
+// topic without a rule of its own.
+const sampleAllTopics = "*"
+
+type sampleMode int
+
+const (
+	// sampleNth keeps every Nth record of each partition.
+	sampleNth sampleMode = iota
+	// sampleRandom keeps a random percentage of records.
+	sampleRandom
+	// sampleKeyHash keeps a percentage of keys, with every record of a kept
+	// key.
+	sampleKeyHash
+)
+
+// sampleRule decides which records of a topic are mirrored.
+type sampleRule struct {
+	mode    sampleMode
+	nth     uint64
+	percent float64
+}
+
+func parseSampleRule(value string) (sampleRule, error) {
+	mode, arg, ok := strings.Cut(value, "=")
+	if !ok {
+		return sampleRule{}, fmt.Errorf("expected nth=N, percent=P or key=P, got %q", value)
+	}
+
+	switch mode {
+	case "nth":
+		n, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil || n == 0 {
+			return sampleRule{}, fmt.Errorf("nth must be a positive integer, got %q", arg)
+		}
+		return sampleRule{mode: sampleNth, nth: n}, nil
+	case "percent", "key":
+		percent, err := strconv.ParseFloat(arg, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return sampleRule{}, fmt.Errorf("%s must be a percentage between 0 and 100, got %q", mode, arg)
+		}
+		if mode == "key" {
+			return sampleRule{mode: sampleKeyHash, percent: percent}, nil
+		}
+		return sampleRule{mode: sampleRandom, percent: percent}, nil
+	default:
+		return sampleRule{}, fmt.Errorf("unknown sampling mode %q", mode)
+	}
+}
+
+// sampleCount counts the records of a topic that were kept and dropped.
+type sampleCount struct {
+	Kept    uint64
+	Dropped uint64
+}
+
+// sampler applies the sampling rules in the mirror loop. A nil *sampler is
+// valid and keeps every record.
+type sampler struct {
+	rules  map[string]sampleRule
+	seen   map[topicPartition]uint64
+	counts map[string]*sampleCount
+}
+
+func newSampler(specs map[string]string) (*sampler, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	s := &sampler{
+		rules:  make(map[string]sampleRule, len(specs)),
+		seen:   map[topicPartition]uint64{},
+		counts: map[string]*sampleCount{},
+	}
+	for topic, spec := range specs {
+		rule, err := parseSampleRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling of topic %q: %w", topic, err)
+		}
+		s.rules[topic] = rule
+	}
+	return s, nil
+}
+
+func (s *sampler) rule(topic string) (sampleRule, bool) {
+	if rule, ok := s.rules[topic]; ok {
+		return rule, true
+	}
+	rule, ok := s.rules[sampleAllTopics]
+	return rule, ok
+}
+
+// keep reports whether r is mirrored, and counts the decision.
+func (s *sampler) keep(r *kgo.Record) bool {
+	if s == nil {
+		return true
+	}
+
+	rule, ok := s.rule(r.Topic)
+	if !ok {
+		return true
+	}
+
+	var keep bool
+	switch rule.mode {
+	case sampleNth:
+		tp := topicPartition{Topic: r.Topic, Partition: r.Partition}
+		keep = s.seen[tp]%rule.nth == 0
+		s.seen[tp]++
+	case sampleRandom:
+		keep = rand.Float64()*100 < rule.percent // #nosec G404
+	case sampleKeyHash:
+		keep = keyBucket(r.Key) < rule.percent
+	}
+
+	count, ok := s.counts[r.Topic]
+	if !ok {
+		count = &sampleCount{}
+		s.counts[r.Topic] = count
+	}
+	if keep {
+		count.Kept++
+	} else {
+		count.Dropped++
+	}
+
+	return keep
+}
+
+// keyBucket maps a key to a stable value in [0, 100).
+func keyBucket(key []byte) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	return float64(h.Sum64()%10000) / 100
+}
+
+// logCounts logs how many records of each sampled topic were kept and
+// dropped.
+func (s *sampler) logCounts(logger *slog.Logger) {
+	if s == nil {
+		return
+	}
+
+	for _, topic := range slices.Sorted(maps.Keys(s.counts)) {
+		count := s.counts[topic]
+		logger.Info("Sampled records",
+			slog.String("topic", topic),
+			slog.Uint64("kept", count.Kept),
+			slog.Uint64("dropped", count.Dropped),
+		)
+	}
+}
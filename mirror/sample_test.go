@@ -0,0 +1,128 @@
+package mirror
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestParseSampleRule(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    sampleRule
+		wantErr bool
+	}{
+		{value: "nth=10", want: sampleRule{mode: sampleNth, nth: 10}},
+		{value: "percent=12.5", want: sampleRule{mode: sampleRandom, percent: 12.5}},
+		{value: "key=50", want: sampleRule{mode: sampleKeyHash, percent: 50}},
+		{value: "nth=0", wantErr: true},
+		{value: "nth=-1", wantErr: true},
+		{value: "percent=101", wantErr: true},
+		{value: "key=abc", wantErr: true},
+		{value: "every=2", wantErr: true},
+		{value: "10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSampleRule(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSampleRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseSampleRule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampler_Nth(t *testing.T) {
+	s, err := newSampler(map[string]string{"orders": "nth=3"})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+
+	var kept []int64
+	for offset := range int64(9) {
+		r := &kgo.Record{Topic: "orders", Partition: 0, Offset: offset}
+		if s.keep(r) {
+			kept = append(kept, offset)
+		}
+	}
+
+	if fmt.Sprint(kept) != "[0 3 6]" {
+		t.Errorf("keep() kept offsets %v, want [0 3 6]", kept)
+	}
+	if c := s.counts["orders"]; c.Kept != 3 || c.Dropped != 6 {
+		t.Errorf("counts = %+v, want 3 kept and 6 dropped", *c)
+	}
+}
+
+func TestSampler_KeyHash(t *testing.T) {
+	s, err := newSampler(map[string]string{sampleAllTopics: "key=30"})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+
+	decisions := map[string]bool{}
+	kept := 0
+	for i := range 1000 {
+		key := fmt.Sprintf("key-%d", i%100)
+		keep := s.keep(&kgo.Record{Topic: "users", Key: []byte(key)})
+		if previous, ok := decisions[key]; ok && previous != keep {
+			t.Fatalf("keep() changed its decision for key %q", key)
+		}
+		decisions[key] = keep
+		if keep {
+			kept++
+		}
+	}
+
+	// 30% of 100 keys, with some slack for the hash distribution.
+	if kept < 100 || kept > 500 {
+		t.Errorf("keep() kept %d of 1000 records, want about 300", kept)
+	}
+}
+
+func TestSampler_Percent(t *testing.T) {
+	s, err := newSampler(map[string]string{"orders": "percent=0", "users": "percent=100"})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+
+	for range 100 {
+		if s.keep(&kgo.Record{Topic: "orders"}) {
+			t.Fatal("keep() kept a record at 0 percent")
+		}
+		if !s.keep(&kgo.Record{Topic: "users"}) {
+			t.Fatal("keep() dropped a record at 100 percent")
+		}
+	}
+}
+
+func TestSampler_Unsampled(t *testing.T) {
+	var nilSampler *sampler
+	if !nilSampler.keep(&kgo.Record{Topic: "orders"}) {
+		t.Error("nil sampler dropped a record")
+	}
+
+	s, err := newSampler(map[string]string{"orders": "nth=2"})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+	for range 10 {
+		if !s.keep(&kgo.Record{Topic: "users"}) {
+			t.Fatal("keep() dropped a record of a topic without a rule")
+		}
+	}
+}
+
+func TestNewSampler_Invalid(t *testing.T) {
+	if _, err := newSampler(map[string]string{"orders": "nth=x"}); err == nil {
+		t.Error("newSampler() expected error, got nil")
+	}
+	if s, err := newSampler(nil); s != nil || err != nil {
+		t.Errorf("newSampler(nil) = %v, %v, want nil, nil", s, err)
+	}
+}
@@ -0,0 +1,204 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Sink is one of several sink clusters of a mirror.
+type Sink struct {
+	// Name identifies the sink in logs, metrics and dry-run plans.
+	Name string
+	// Client holds the options of its Kafka client, as Options.Sink.
+	Client []kgo.Opt
+	// Topics are the mirrored topics produced to the sink, every topic if
+	// empty.
+	Topics []string
+}
+
+// sinkMirror is the mirror of the topics produced to a sink, with the
+// options of that sink. A mirror with a single sink is its own sinkMirror.
+type sinkMirror struct {
+	name   string
+	mirror *Mirror
+}
+
+// sinkTarget is the state of a run for a sink: its clients, its plan and the
+// delivery of the records produced to it. A sink that failed to produce a
+// record is dropped and produces nothing anymore.
+type sinkTarget struct {
+	sinkMirror
+	log *slog.Logger
+	// opts are the options of client, also used by codecs.
+	opts    []kgo.Opt
+	client  *kgo.Client
+	admin   *kadm.Client
+	cluster clusterIdentity
+	plan    mirrorPlan
+	// resumed holds the checkpoints of an exactly-once mirror resumed from,
+	// by source partition.
+	resumed map[topicPartition]int64
+
+	router  *router
+	offsets *OffsetMap
+	groups  *groupSyncer
+	codecs  *codecProducer
+	metrics *mirrorMetrics
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+
+	dropped atomic.Bool
+	// onDrop, if set, is called once the sink is dropped.
+	onDrop func()
+	mu     sync.Mutex
+	err    error
+}
+
+// sinkMetricsSide returns the subsystem of the client metrics of a sink,
+// sink for a mirror with a single sink.
+func sinkMetricsSide(name string) string {
+	if name == "" {
+		return sideSink
+	}
+	return sideSink + "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// active reports whether the sink wasn't dropped.
+func (t *sinkTarget) active() bool {
+	return !t.dropped.Load()
+}
+
+// mirrors reports whether records of the source topic are produced to the
+// sink.
+func (t *sinkTarget) mirrors(topic string) bool {
+	_, ok := t.mirror.topics[topic]
+	return ok && t.active()
+}
+
+// fail drops the sink, keeping the first error.
+func (t *sinkTarget) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return
+	}
+	t.err = err
+	t.dropped.Store(true)
+	if t.name != "" {
+		t.log.Error("Dropping sink", slog.Any("error", err))
+	}
+	if t.onDrop != nil {
+		t.onDrop()
+	}
+}
+
+// failure returns the error the sink was dropped for, nil if it wasn't.
+func (t *sinkTarget) failure() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err == nil {
+		return nil
+	}
+	if t.name == "" {
+		return t.err
+	}
+	return fmt.Errorf("sink %s: %w", t.name, t.err)
+}
+
+// produce produces r to the sink. r is modified, so it must not be produced
+// to another sink.
+func (t *sinkTarget) produce(ctx context.Context, r *kgo.Record) {
+	opts := &t.mirror.opts
+
+	client := t.client
+	if t.codecs != nil {
+		var err error
+		if client, err = t.codecs.client(ctx, r); err != nil {
+			t.fail(err)
+			return
+		}
+	}
+
+	// The sink partition and offset replace the source ones of r once
+	// produced.
+	tp, sourceOffset := topicPartition{Topic: r.Topic, Partition: r.Partition}, r.Offset
+	t.router.route(r)
+	r.Topic = t.mirror.sinkTopic(r.Topic)
+	if opts.Provenance != "" {
+		r.Headers = append(r.Headers, kgo.RecordHeader{Key: ProvenanceHeader, Value: []byte(opts.Provenance)})
+	}
+	produced := time.Now()
+	client.Produce(ctx, r, func(r *kgo.Record, err error) {
+		t.metrics.produced(t.name, r, produced, err)
+		if err != nil {
+			t.failed.Add(1)
+			t.log.LogAttrs(ctx, slog.LevelError, "Failed to produce record", append(recordAttrs(r), slog.Any("error", err))...)
+			t.fail(fmt.Errorf("failed to produce record: %w", err))
+			return
+		}
+		t.delivered.Add(1)
+		t.offsets.record(tp, sourceOffset, r.Offset)
+		logRecord(ctx, t.log, opts.LogRecordSampling, "Produced record", r)
+	})
+}
+
+// flush waits for the records produced to the sink, and drops it if they
+// can't be.
+func (t *sinkTarget) flush(ctx context.Context) {
+	if err := t.client.Flush(ctx); err != nil {
+		t.fail(fmt.Errorf("failed to flush records: %w", err))
+		return
+	}
+	if t.codecs != nil {
+		if err := t.codecs.Flush(ctx); err != nil {
+			t.fail(fmt.Errorf("failed to flush records: %w", err))
+		}
+	}
+}
+
+// close closes the clients of the sink.
+func (t *sinkTarget) close() {
+	if t.codecs != nil {
+		t.codecs.Close()
+	}
+	if t.client != nil {
+		t.client.Close()
+	}
+}
+
+// cloneRecord returns a copy of r that can be produced without modifying r.
+func cloneRecord(r *kgo.Record) *kgo.Record {
+	clone := *r
+	clone.Headers = slices.Clone(r.Headers)
+	return &clone
+}
+
+// checkDistinctSinks refuses to mirror twice into the same cluster, which
+// would delete the topics mirrored to it by the other sink.
+func checkDistinctSinks(targets []*sinkTarget) error {
+	for i, a := range targets {
+		for _, b := range targets[i+1:] {
+			if checkDistinctClusters(a.cluster, b.cluster) != nil {
+				return fmt.Errorf("sinks %s and %s are the same cluster %s", a.name, b.name, b.cluster)
+			}
+		}
+	}
+	return nil
+}
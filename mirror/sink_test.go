@@ -0,0 +1,150 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestSinkMetricsSide(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "", want: "sink"},
+		{name: "alice", want: "sink_alice"},
+		{name: "bob-laptop.local", want: "sink_bob_laptop_local"},
+	}
+
+	for _, tt := range tests {
+		if got := sinkMetricsSide(tt.name); got != tt.want {
+			t.Errorf("sinkMetricsSide(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDistinctSinks(t *testing.T) {
+	target := func(name, id string, hosts ...string) *sinkTarget {
+		return &sinkTarget{sinkMirror: sinkMirror{name: name}, cluster: clusterIdentity{ID: id, Hosts: hosts}}
+	}
+
+	tests := []struct {
+		name    string
+		targets []*sinkTarget
+		wantErr bool
+	}{
+		{name: "single", targets: []*sinkTarget{target("alice", "a")}},
+		{name: "distinct", targets: []*sinkTarget{target("alice", "a"), target("bob", "b")}},
+		{name: "same cluster", targets: []*sinkTarget{target("alice", "a"), target("bob", "b"), target("carol", "a")}, wantErr: true},
+		{name: "shared broker", targets: []*sinkTarget{target("alice", "", "h1:9092"), target("bob", "", "h1:9092", "h2:9092")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkDistinctSinks(tt.targets); (err != nil) != tt.wantErr {
+				t.Errorf("checkDistinctSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// failProduces makes every produce to cluster fail with a non-retryable
+// error.
+func failProduces(cluster *kfake.Cluster) {
+	var failing atomic.Bool
+	failing.Store(true)
+	failProducesWhile(cluster, &failing)
+}
+
+// failProducesWhile makes every produce to cluster fail with a non-retryable
+// error while failing is set.
+func failProducesWhile(cluster *kfake.Cluster, failing *atomic.Bool) {
+	cluster.ControlKey(int16(kmsg.Produce), func(req kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		if !failing.Load() {
+			return nil, nil, false
+		}
+
+		produceReq := req.(*kmsg.ProduceRequest)
+		resp := produceReq.ResponseKind().(*kmsg.ProduceResponse)
+		for _, rt := range produceReq.Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic = rt.Topic
+			st.TopicID = rt.TopicID
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.ErrorCode = kerr.TopicAuthorizationFailed.Code
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil, true
+	})
+}
+
+func TestMirror_Sinks(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders", "users")
+	alice := newFakeCluster(t, 1)
+	bob := newFakeCluster(t, 1)
+
+	broken, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.ClusterID(fmt.Sprintf("kfake-%d", fakeClusters.Add(1))))
+	if err != nil {
+		t.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	t.Cleanup(broken.Close)
+	failProduces(broken)
+
+	produceValues(t, source, "orders", "o0")
+	produceValues(t, source, "users", "u0")
+
+	opts := testOptions(t, source, nil, "orders@-2", "users@-2")
+	opts.Sink = nil
+	opts.Sinks = []Sink{
+		{Name: "alice", Client: []kgo.Opt{kgo.SeedBrokers(alice...)}},
+		{Name: "bob", Client: []kgo.Opt{kgo.SeedBrokers(bob...)}, Topics: []string{"users"}},
+		{Name: "broken", Client: []kgo.Opt{kgo.SeedBrokers(broken.ListenAddrs()...)}},
+	}
+
+	stop := startMirror(t, newTestMirror(t, opts))
+	consumeRecords(t, alice, 2, "orders", "users")
+	consumeRecords(t, bob, 1, "users")
+
+	// The broken sink doesn't stop the mirror to the other sinks.
+	produceValues(t, source, "orders", "o1")
+	consumeRecords(t, alice, 3, "orders", "users")
+
+	err = stop()
+	var sinkErr *SinkWriteError
+	if !errors.As(err, &sinkErr) || !strings.Contains(err.Error(), "sink broken") {
+		t.Errorf("Run() error = %v, want a *SinkWriteError of sink broken", err)
+	}
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(bob...))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	topics, err := kadm.NewClient(client).ListTopics(ctx)
+	if err != nil {
+		t.Fatalf("ListTopics() error = %v", err)
+	}
+	if topics.Has("orders") {
+		t.Error("topic orders was mirrored to sink bob, which only mirrors users")
+	}
+}
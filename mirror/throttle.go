@@ -0,0 +1,230 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"golang.org/x/time/rate"
+)
+
+// ThrottleOptions defines the throughput limits of a mirror. A limit that
+// isn't positive is no limit.
+type ThrottleOptions struct {
+	// MaxRecordsPerSec and MaxBytesPerSec limit the records, and the key and
+	// value bytes, mirrored per second across all topics.
+	MaxRecordsPerSec float64
+	MaxBytesPerSec   float64
+	// TopicMaxRecordsPerSec and TopicMaxBytesPerSec limit them per topic.
+	TopicMaxRecordsPerSec map[string]float64
+	TopicMaxBytesPerSec   map[string]float64
+}
+
+// throttle limits the throughput of the mirror loop with token buckets,
+// globally and per topic. A nil *throttle is valid and never waits.
+type throttle struct {
+	records      *rate.Limiter
+	bytes        *rate.Limiter
+	topicRecords map[string]*rate.Limiter
+	topicBytes   map[string]*rate.Limiter
+}
+
+func newThrottle(opts ThrottleOptions) (*throttle, error) {
+	t := &throttle{
+		records:      newLimiter(opts.MaxRecordsPerSec),
+		bytes:        newLimiter(opts.MaxBytesPerSec),
+		topicRecords: map[string]*rate.Limiter{},
+		topicBytes:   map[string]*rate.Limiter{},
+	}
+
+	for topic, limit := range opts.TopicMaxRecordsPerSec {
+		if limit <= 0 {
+			return nil, fmt.Errorf("records per second of topic %q must be positive, got %v", topic, limit)
+		}
+		t.topicRecords[topic] = newLimiter(limit)
+	}
+
+	for topic, limit := range opts.TopicMaxBytesPerSec {
+		if limit <= 0 {
+			return nil, fmt.Errorf("bytes per second of topic %q must be positive, got %v", topic, limit)
+		}
+		t.topicBytes[topic] = newLimiter(limit)
+	}
+
+	if t.records == nil && t.bytes == nil && len(t.topicRecords) == 0 && len(t.topicBytes) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// newLimiter returns a token bucket refilled at limit tokens per second that
+// holds at most one second worth of tokens, or nil if limit isn't positive.
+func newLimiter(limit float64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(max(math.Ceil(limit), 1)))
+}
+
+// wait blocks until r may be produced without exceeding any limit.
+func (t *throttle) wait(ctx context.Context, r *kgo.Record) error {
+	if t == nil {
+		return nil
+	}
+
+	size := len(r.Key) + len(r.Value)
+	for _, wait := range []struct {
+		limiter *rate.Limiter
+		n       int
+	}{
+		{t.records, 1},
+		{t.topicRecords[r.Topic], 1},
+		{t.bytes, size},
+		{t.topicBytes[r.Topic], size},
+	} {
+		if err := waitN(ctx, wait.limiter, wait.n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitN waits for n tokens, in chunks no bigger than the bucket, so records
+// larger than a second worth of bytes are still let through eventually.
+func waitN(ctx context.Context, limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		chunk := min(n, limiter.Burst())
+		if err := limiter.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// pauser pauses and resumes fetching of the mirrored topics. It pauses whole
+// topics, which the client tracks apart from the partitions paused by the
+// pipeline for backpressure.
+type pauser struct {
+	client     *kgo.Client
+	partitions map[string][]int32
+
+	mu     sync.Mutex
+	paused map[string]bool
+}
+
+func newPauser(client *kgo.Client, plan mirrorPlan) *pauser {
+	p := &pauser{
+		client:     client,
+		partitions: map[string][]int32{},
+		paused:     map[string]bool{},
+	}
+	for _, topic := range plan.Create {
+		for _, partition := range topic.Offsets {
+			p.partitions[topic.Topic] = append(p.partitions[topic.Topic], partition.Partition)
+		}
+	}
+	return p
+}
+
+// pause stops fetching the given topics, or every topic if none is given.
+func (p *pauser) pause(topics ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	selected := p.selectPartitions(topics)
+	p.client.PauseFetchTopics(slices.Collect(maps.Keys(selected))...)
+	for topic := range selected {
+		p.paused[topic] = true
+	}
+	slog.Info("Paused fetching", slog.Any("topics", slices.Sorted(maps.Keys(selected))))
+}
+
+// resume restarts fetching the given topics, or every topic if none is
+// given.
+func (p *pauser) resume(topics ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	selected := p.selectPartitions(topics)
+	p.client.ResumeFetchTopics(slices.Collect(maps.Keys(selected))...)
+	for topic := range selected {
+		delete(p.paused, topic)
+	}
+	slog.Info("Resumed fetching", slog.Any("topics", slices.Sorted(maps.Keys(selected))))
+}
+
+func (p *pauser) pausedTopics() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(p.paused))
+}
+
+func (p *pauser) selectPartitions(topics []string) map[string][]int32 {
+	if len(topics) == 0 {
+		return p.partitions
+	}
+
+	out := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		if partitions, ok := p.partitions[topic]; ok {
+			out[topic] = partitions
+		}
+	}
+	return out
+}
+
+// handler serves POST /pause and POST /resume, optionally limited to the
+// topics given as topic query parameters, and GET /paused.
+func (p *pauser) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		p.pause(r.URL.Query()["topic"]...)
+		p.writeStatus(w)
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		p.resume(r.URL.Query()["topic"]...)
+		p.writeStatus(w)
+	})
+	mux.HandleFunc("GET /paused", func(w http.ResponseWriter, _ *http.Request) {
+		p.writeStatus(w)
+	})
+	return mux
+}
+
+func (p *pauser) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]string{"paused": p.pausedTopics()})
+}
+
+// serve exposes the pause and resume endpoints on addr until the returned
+// server is closed.
+func (p *pauser) serve(addr string) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           p.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Control server stopped", slog.String("addr", addr), slog.Any("error", err))
+		}
+	}()
+
+	return server
+}
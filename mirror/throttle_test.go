@@ -0,0 +1,137 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestNewThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ThrottleOptions
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no limits", opts: ThrottleOptions{}, wantNil: true},
+		{name: "global records", opts: ThrottleOptions{MaxRecordsPerSec: 10}},
+		{name: "topic bytes", opts: ThrottleOptions{TopicMaxBytesPerSec: map[string]float64{"orders": 1024}}},
+		{name: "invalid topic limit", opts: ThrottleOptions{TopicMaxRecordsPerSec: map[string]float64{"orders": 0}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newThrottle(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newThrottle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("newThrottle() = %v, want nil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestThrottle_Wait(t *testing.T) {
+	th, err := newThrottle(ThrottleOptions{TopicMaxRecordsPerSec: map[string]float64{"orders": 20}})
+	if err != nil {
+		t.Fatalf("newThrottle() error = %v", err)
+	}
+
+	ctx := context.Background()
+	started := time.Now()
+
+	// The bucket holds one second worth of tokens, so the first 20 records
+	// pass immediately and the next 5 take about a quarter of a second.
+	for range 25 {
+		if err := th.wait(ctx, &kgo.Record{Topic: "orders"}); err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 200*time.Millisecond {
+		t.Errorf("wait() took %v for 25 records at 20/s, want at least 200ms", elapsed)
+	}
+
+	// Other topics are not limited.
+	started = time.Now()
+	for range 100 {
+		if err := th.wait(ctx, &kgo.Record{Topic: "users"}); err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Errorf("wait() took %v for an unlimited topic", elapsed)
+	}
+}
+
+func TestThrottle_WaitLargeRecord(t *testing.T) {
+	th, err := newThrottle(ThrottleOptions{MaxBytesPerSec: 1000})
+	if err != nil {
+		t.Fatalf("newThrottle() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A record bigger than the bucket must not fail, only wait.
+	if err := th.wait(ctx, &kgo.Record{Value: make([]byte, 1500)}); err != nil {
+		t.Errorf("wait() error = %v", err)
+	}
+}
+
+func TestThrottle_Nil(t *testing.T) {
+	var th *throttle
+	if err := th.wait(context.Background(), &kgo.Record{}); err != nil {
+		t.Errorf("wait() error = %v", err)
+	}
+}
+
+func TestPauser_Handler(t *testing.T) {
+	client, err := kgo.NewClient()
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	plan := mirrorPlan{Create: []plannedTopic{
+		{Topic: "orders", Offsets: []plannedPartition{{Partition: 0}, {Partition: 1}}},
+		{Topic: "users", Offsets: []plannedPartition{{Partition: 0}}},
+	}}
+	handler := newPauser(client, plan).handler()
+
+	do := func(method, target string) []string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s status = %d", method, target, rec.Code)
+		}
+
+		var status map[string][]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s %s returned invalid JSON: %v", method, target, err)
+		}
+		return status["paused"]
+	}
+
+	if got := do(http.MethodPost, "/pause?topic=orders"); len(got) != 1 || got[0] != "orders" {
+		t.Errorf("pause orders: paused = %v, want [orders]", got)
+	}
+	if got := do(http.MethodPost, "/pause"); len(got) != 2 {
+		t.Errorf("pause all: paused = %v, want [orders users]", got)
+	}
+	if got := do(http.MethodPost, "/resume?topic=users"); len(got) != 1 || got[0] != "orders" {
+		t.Errorf("resume users: paused = %v, want [orders]", got)
+	}
+	if got := do(http.MethodGet, "/paused"); len(got) != 1 {
+		t.Errorf("status: paused = %v, want [orders]", got)
+	}
+	if got := client.PauseFetchTopics(); len(got) != 1 || got[0] != "orders" {
+		t.Errorf("client paused topics = %v, want [orders]", got)
+	}
+}
@@ -0,0 +1,166 @@
+package mirror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Topic is a topic to mirror and the offsets to start from.
+//
+// Offsets are absolute, except -1 for the end and -2 or lower for the start
+// of a partition.
+type Topic struct {
+	Name string
+	// Sink is the name of the topic on the sink, Name if empty.
+	Sink string
+	// Offset is the offset of every partition, if PerPartitionOffset is nil.
+	Offset int64
+	// PerPartitionOffset holds the offset of each partition to mirror. Other
+	// partitions aren't mirrored.
+	PerPartitionOffset map[int32]int64
+}
+
+// SinkName returns the name of the topic on the sink.
+func (t Topic) SinkName() string {
+	if t.Sink == "" {
+		return t.Name
+	}
+	return t.Sink
+}
+
+// OffsetOf returns the offset to start partition from, and whether the
+// partition is mirrored at all.
+func (t Topic) OffsetOf(partition int32) (int64, bool) {
+	if t.PerPartitionOffset == nil {
+		return t.Offset, true
+	}
+
+	offset, ok := t.PerPartitionOffset[partition]
+	return offset, ok
+}
+
+// ParseTopics parses topic arguments, as accepted by ParseTopic, in the order
+// given.
+func ParseTopics(values []string) ([]Topic, error) {
+	if len(values) == 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("no topics specified")}
+	}
+
+	topics := make([]Topic, 0, len(values))
+	for _, value := range values {
+		topic, err := ParseTopic(value)
+		if err != nil {
+			return nil, &ConfigError{Err: fmt.Errorf("failed to parse topic %q: %w", value, err)}
+		}
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
+
+// ParseTopic parses a topic argument: topic to mirror from the end,
+// topic@offset to mirror every partition from offset, or
+// topic@partition:offset,partition:offset... to mirror only the given
+// partitions.
+func ParseTopic(value string) (Topic, error) {
+	parts := strings.Split(value, "@")
+
+	switch len(parts) {
+	case 1:
+		return Topic{Name: parts[0], Offset: -1}, nil
+	case 2:
+		topic, err := parseTopicOffset(parts[1])
+		topic.Name = parts[0]
+		return topic, err
+	default:
+		return Topic{Name: parts[0]}, fmt.Errorf("expected topic@offset or topic@partition:offset,partition:offset... got %q", value)
+	}
+}
+
+func parseTopicOffset(value string) (Topic, error) {
+	offsetsOrOffsetPerPartition := strings.Split(value, ",")
+	if len(offsetsOrOffsetPerPartition) == 1 {
+		offset, err := strconv.ParseInt(offsetsOrOffsetPerPartition[0], 10, 64)
+		if err != nil {
+			return Topic{}, fmt.Errorf("failed to parse offset %q: %w", offsetsOrOffsetPerPartition[0], err)
+		}
+
+		return Topic{Offset: offset}, nil
+	}
+
+	out := Topic{
+		PerPartitionOffset: map[int32]int64{},
+	}
+	for _, offsets := range offsetsOrOffsetPerPartition {
+		partitionOffset := strings.Split(offsets, ":")
+		if len(partitionOffset) != 2 {
+			return out, fmt.Errorf("expected partition:offset, got %q", offsets)
+		}
+
+		partition, err := strconv.ParseInt(partitionOffset[0], 10, 32)
+		if err != nil {
+			return out, fmt.Errorf("failed to parse partition %q: %w", partitionOffset[0], err)
+		}
+
+		offset, err := strconv.ParseInt(partitionOffset[1], 10, 64)
+		if err != nil {
+			return out, fmt.Errorf("failed to parse offset %q: %w", partitionOffset[1], err)
+		}
+
+		out.PerPartitionOffset[int32(partition)] = offset
+	}
+
+	return out, nil
+}
+
+// TopicNames returns the names of topics.
+func TopicNames(topics []Topic) []string {
+	names := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	return names
+}
+
+// CheckTopics returns a *MissingTopicError if any of topics isn't among the
+// source topics.
+func CheckTopics(sourceTopics kadm.TopicDetails, topics []Topic) error {
+	missingTopics := make([]string, 0)
+
+	for _, topic := range topics {
+		if !sourceTopics.Has(topic.Name) {
+			missingTopics = append(missingTopics, topic.Name)
+		}
+	}
+
+	if len(missingTopics) > 0 {
+		return &MissingTopicError{Topics: missingTopics}
+	}
+	return nil
+}
+
+// ConsumePartitions returns the partitions of topics to consume and their
+// start offsets, as given to kgo.ConsumePartitions or AddConsumePartitions.
+func ConsumePartitions(sourceTopics kadm.TopicDetails, topics []Topic) map[string]map[int32]kgo.Offset {
+	partitions := map[string]map[int32]kgo.Offset{}
+	for _, topic := range topics {
+		dt, ok := sourceTopics[topic.Name]
+		if !ok {
+			continue
+		}
+
+		offsets := map[int32]kgo.Offset{}
+		for _, partition := range dt.Partitions {
+			if offset, ok := topic.OffsetOf(partition.Partition); ok {
+				offsets[partition.Partition] = kgo.NewOffset().At(offset)
+			}
+		}
+
+		partitions[topic.Name] = offsets
+	}
+	return partitions
+}
@@ -0,0 +1,427 @@
+package mirror
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func TestTopic_OffsetOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       Topic
+		partition int32
+		want      int64
+		wantOk    bool
+	}{
+		{
+			name:      "returns offset when PerPartitionOffset is nil",
+			opt:       Topic{Offset: 100},
+			partition: 0,
+			want:      100,
+			wantOk:    true,
+		},
+		{
+			name: "returns offset when partition exists in PerPartitionOffset",
+			opt: Topic{
+				Offset:             0,
+				PerPartitionOffset: map[int32]int64{0: 50, 1: 100},
+			},
+			partition: 1,
+			want:      100,
+			wantOk:    true,
+		},
+		{
+			name: "returns not found when partition doesn't exist in PerPartitionOffset",
+			opt: Topic{
+				Offset:             0,
+				PerPartitionOffset: map[int32]int64{0: 50},
+			},
+			partition: 1,
+			want:      0,
+			wantOk:    false,
+		},
+		{
+			name:      "returns zero offset with empty PerPartitionOffset",
+			opt:       Topic{PerPartitionOffset: map[int32]int64{}},
+			partition: 0,
+			want:      0,
+			wantOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := tt.opt.OffsetOf(tt.partition)
+			if got != tt.want {
+				t.Errorf("OffsetOf() got = %v, want %v", got, tt.want)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("OffsetOf() gotOk = %v, wantOk %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTopic_OffsetOf_MultiplePartitions(t *testing.T) {
+	opt := Topic{
+		PerPartitionOffset: map[int32]int64{
+			0: 100,
+			1: 200,
+			2: 300,
+		},
+	}
+
+	tests := []struct {
+		partition int32
+		want      int64
+		wantOk    bool
+	}{
+		{0, 100, true},
+		{1, 200, true},
+		{2, 300, true},
+		{3, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			got, gotOk := opt.OffsetOf(tt.partition)
+			if got != tt.want {
+				t.Errorf("OffsetOf() partition %d got = %v, want %v", tt.partition, got, tt.want)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("OffsetOf() partition %d gotOk = %v, wantOk %v", tt.partition, gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTopic_OffsetOf_NegativeOffset(t *testing.T) {
+	opt := Topic{
+		Offset: -1, // Represents "latest" or "end"
+	}
+
+	got, gotOk := opt.OffsetOf(0)
+	if got != -1 {
+		t.Errorf("OffsetOf() got = %v, want -1", got)
+	}
+	if !gotOk {
+		t.Errorf("OffsetOf() gotOk = false, want true")
+	}
+}
+
+func TestParseTopicOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Topic
+		wantErr bool
+	}{
+		{
+			name:    "single offset - positive",
+			value:   "100",
+			want:    Topic{Offset: 100},
+			wantErr: false,
+		},
+		{
+			name:    "single offset - zero",
+			value:   "0",
+			want:    Topic{Offset: 0},
+			wantErr: false,
+		},
+		{
+			name:    "single offset - negative",
+			value:   "-1",
+			want:    Topic{Offset: -1},
+			wantErr: false,
+		},
+		{
+			name:    "single partition offset (comma required for multiple)",
+			value:   "0:100",
+			want:    Topic{},
+			wantErr: true,
+		},
+		{
+			name:  "multiple partition offsets",
+			value: "0:100,1:200,2:300",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{0: 100, 1: 200, 2: 300},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid offset - non-numeric",
+			value:   "abc",
+			want:    Topic{},
+			wantErr: true,
+		},
+		{
+			name:  "invalid partition offset - missing colon",
+			value: "0-100",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "invalid partition offset - invalid partition",
+			value: "abc:100",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "invalid partition offset - invalid offset",
+			value: "0:abc",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "invalid partition offset - three parts",
+			value: "0:100:200",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "partition offsets with negative values",
+			value: "0:-1,1:0",
+			want: Topic{
+				PerPartitionOffset: map[int32]int64{0: -1, 1: 0},
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTopicOffset(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTopicOffset() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if got.Offset != tt.want.Offset {
+					t.Errorf("parseTopicOffset() Offset = %v, want %v", got.Offset, tt.want.Offset)
+				}
+				if len(got.PerPartitionOffset) != len(tt.want.PerPartitionOffset) {
+					t.Errorf("parseTopicOffset() PerPartitionOffset length = %v, want %v", len(got.PerPartitionOffset), len(tt.want.PerPartitionOffset))
+				}
+				for k, v := range tt.want.PerPartitionOffset {
+					if got.PerPartitionOffset[k] != v {
+						t.Errorf("parseTopicOffset() PerPartitionOffset[%d] = %v, want %v", k, got.PerPartitionOffset[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantOpt Topic
+		wantErr bool
+	}{
+		{
+			name:    "topic only",
+			value:   "my-topic",
+			want:    "my-topic",
+			wantOpt: Topic{Offset: -1},
+			wantErr: false,
+		},
+		{
+			name:    "topic with offset",
+			value:   "my-topic@100",
+			want:    "my-topic",
+			wantOpt: Topic{Offset: 100},
+			wantErr: false,
+		},
+		{
+			name:    "topic with partition offsets",
+			value:   "my-topic@0:100,1:200",
+			want:    "my-topic",
+			wantOpt: Topic{PerPartitionOffset: map[int32]int64{0: 100, 1: 200}},
+			wantErr: false,
+		},
+		{
+			name:    "topic with dash",
+			value:   "my-topic-123",
+			want:    "my-topic-123",
+			wantOpt: Topic{Offset: -1},
+			wantErr: false,
+		},
+		{
+			name:    "topic with offset zero",
+			value:   "my-topic@0",
+			want:    "my-topic",
+			wantOpt: Topic{Offset: 0},
+			wantErr: false,
+		},
+		{
+			name:    "invalid - multiple at signs",
+			value:   "my-topic@100@200",
+			want:    "my-topic",
+			wantOpt: Topic{},
+			wantErr: true,
+		},
+		{
+			name:    "empty topic name with offset (valid by parsing logic)",
+			value:   "@100",
+			want:    "",
+			wantOpt: Topic{Offset: 100},
+			wantErr: false,
+		},
+		{
+			name:    "invalid - bad offset format",
+			value:   "my-topic@abc",
+			want:    "my-topic",
+			wantOpt: Topic{},
+			wantErr: true,
+		},
+		{
+			name:    "topic with underscores",
+			value:   "my_topic_123",
+			want:    "my_topic_123",
+			wantOpt: Topic{Offset: -1},
+			wantErr: false,
+		},
+		{
+			name:    "topic with dots",
+			value:   "my.topic.name",
+			want:    "my.topic.name",
+			wantOpt: Topic{Offset: -1},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOpt, err := ParseTopic(tt.value)
+			got := gotOpt.Name
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTopic() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseTopic() got = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr {
+				if gotOpt.Offset != tt.wantOpt.Offset {
+					t.Errorf("ParseTopic() Offset = %v, want %v", gotOpt.Offset, tt.wantOpt.Offset)
+				}
+				if len(gotOpt.PerPartitionOffset) != len(tt.wantOpt.PerPartitionOffset) {
+					t.Errorf("ParseTopic() PerPartitionOffset length = %v, want %v", len(gotOpt.PerPartitionOffset), len(tt.wantOpt.PerPartitionOffset))
+				}
+				for k, v := range tt.wantOpt.PerPartitionOffset {
+					if gotOpt.PerPartitionOffset[k] != v {
+						t.Errorf("ParseTopic() PerPartitionOffset[%d] = %v, want %v", k, gotOpt.PerPartitionOffset[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseTopic_LongTopicNames(t *testing.T) {
+	longTopic := strings.Repeat("a", 249) + "@100"
+	gotOpt, err := ParseTopic(longTopic)
+	got := gotOpt.Name
+
+	if err != nil {
+		t.Errorf("ParseTopic() unexpected error: %v", err)
+	}
+	if len(got) != 249 {
+		t.Errorf("ParseTopic() got topic length = %v, want 249", len(got))
+	}
+	if gotOpt.Offset != 100 {
+		t.Errorf("ParseTopic() Offset = %v, want 100", gotOpt.Offset)
+	}
+}
+
+func BenchmarkParseTopicOffset(b *testing.B) {
+	tests := []string{
+		"100",
+		"0:100,1:200,2:300",
+		"0:100,1:200,2:300,3:400,4:500,5:600,6:700,7:800",
+	}
+
+	for _, tt := range tests {
+		b.Run(tt, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = parseTopicOffset(tt)
+			}
+		})
+	}
+}
+
+func BenchmarkParseTopic(b *testing.B) {
+	tests := []string{
+		"my-topic",
+		"my-topic@100",
+		"my-topic@0:100,1:200,2:300",
+	}
+
+	for _, tt := range tests {
+		b.Run(tt, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = ParseTopic(tt)
+			}
+		})
+	}
+}
+
+func TestParseTopics(t *testing.T) {
+	topics, err := ParseTopics([]string{"orders@-2", "users"})
+	if err != nil {
+		t.Fatalf("ParseTopics() error = %v", err)
+	}
+	if names := TopicNames(topics); !slices.Equal(names, []string{"orders", "users"}) {
+		t.Errorf("ParseTopics() names = %v, want [orders users]", names)
+	}
+
+	for _, values := range [][]string{nil, {"orders", "users@abc"}} {
+		var configErr *ConfigError
+		if _, err := ParseTopics(values); !errors.As(err, &configErr) {
+			t.Errorf("ParseTopics(%q) error = %v, want *ConfigError", values, err)
+		}
+	}
+}
+
+func TestCheckTopics_Missing(t *testing.T) {
+	topics := []Topic{{Name: "orders", Offset: -1}, {Name: "users", Offset: -1}}
+
+	err := CheckTopics(kadm.TopicDetails{"orders": {Topic: "orders"}}, topics)
+
+	var missing *MissingTopicError
+	if !errors.As(err, &missing) {
+		t.Fatalf("CheckTopics() error = %v, want *MissingTopicError", err)
+	}
+	if len(missing.Topics) != 1 || missing.Topics[0] != "users" {
+		t.Errorf("CheckTopics() missing topics = %v, want [users]", missing.Topics)
+	}
+}
+
+func TestConsumePartitions(t *testing.T) {
+	sourceTopics := kadm.TopicDetails{
+		"orders": {Topic: "orders", Partitions: kadm.PartitionDetails{0: {Partition: 0}, 1: {Partition: 1}}},
+	}
+	topics := []Topic{{Name: "orders", PerPartitionOffset: map[int32]int64{1: 5}}}
+
+	got := ConsumePartitions(sourceTopics, topics)
+	if len(got["orders"]) != 1 {
+		t.Fatalf("ConsumePartitions() = %v, want only partition 1 of orders", got)
+	}
+	if offset := got["orders"][1].EpochOffset().Offset; offset != 5 {
+		t.Errorf("ConsumePartitions() offset of partition 1 = %d, want 5", offset)
+	}
+}
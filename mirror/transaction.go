@@ -0,0 +1,370 @@
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ExactlyOnceOptions defines the exactly-once mode of a mirror. With a
+// TransactionalID, records are produced to the sink in transactions, each
+// committed along with checkpoint records of the next source offset of
+// every partition. A mirror restarted with the same TransactionalID resumes
+// from the last committed checkpoints and keeps its sink topics, so
+// consumers reading committed records of the sink see every record once.
+type ExactlyOnceOptions struct {
+	// TransactionalID identifies the mirror across runs, disabled if empty.
+	TransactionalID string
+	// CheckpointTopic is the sink topic of the checkpoints, kmir-checkpoints
+	// if empty. It is created compacted, with a single partition, if
+	// missing.
+	CheckpointTopic string
+	// CommitInterval is how often transactions are committed. It defaults
+	// to a second.
+	CommitInterval time.Duration
+}
+
+// checkpoint is the value of a checkpoint record: the next offset of a
+// source partition mirrored by the mirror of a transactional ID.
+type checkpoint struct {
+	ID        string `json:"id"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// key returns the key of the checkpoint record, so the checkpoint topic is
+// compacted to the last checkpoint of every partition.
+func (c checkpoint) key() []byte {
+	return []byte(c.ID + "/" + c.Topic + "/" + strconv.Itoa(int(c.Partition)))
+}
+
+// checkExactlyOnce refuses the options the exactly-once mode doesn't
+// support: they produce records out of their source order, through other
+// clients, or need sink topics recreated on every run.
+func checkExactlyOnce(opts Options) error {
+	if opts.ExactlyOnce.TransactionalID == "" {
+		return nil
+	}
+
+	switch {
+	case len(opts.Sinks) > 0:
+		return fmt.Errorf("exactly-once isn't supported with several sinks")
+	case opts.PreserveCompression:
+		return fmt.Errorf("exactly-once isn't supported when preserving compression")
+	case len(opts.Latest.Topics) > 0:
+		return fmt.Errorf("exactly-once isn't supported with latest-per-key topics")
+	case len(opts.Groups) > 0 || opts.OffsetMapFile != "":
+		return fmt.Errorf("consumer groups and offset map aren't supported with exactly-once")
+	}
+
+	checkpointTopic := cmp.Or(opts.ExactlyOnce.CheckpointTopic, defaultCheckpointTopic)
+	for _, topic := range opts.Topics {
+		if topic.SinkName() == checkpointTopic {
+			return fmt.Errorf("topic %q is mirrored to the checkpoint topic", topic.Name)
+		}
+	}
+	return nil
+}
+
+// fence initializes the producer ID of the transactional ID of client. It
+// fences a previous mirror of the same transactional ID that may still be
+// running, and ends its open transaction, so it can't commit checkpoints
+// once they are read.
+func (m *Mirror) fence(rootCtx context.Context, client *kgo.Client) error {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	if _, _, err := client.ProducerID(ctx); err != nil {
+		return fmt.Errorf("failed to initialize transactional producer: %w", err)
+	}
+	return nil
+}
+
+// readCheckpoints returns the committed checkpoints of the mirror in the
+// checkpoint topic of the sink, by source partition, nil if there are none.
+// Only listing the offsets of the topic is bounded by the timeout: its
+// records are read up to the last stable offset however long it takes.
+func (m *Mirror) readCheckpoints(rootCtx context.Context, admin *kadm.Client) (map[topicPartition]int64, error) {
+	opts := &m.opts.ExactlyOnce
+
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	starts, err := admin.ListStartOffsets(ctx, opts.CheckpointTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoint offsets: %w", err)
+	}
+	// The last stable offset ends the records of committed transactions.
+	ends, err := admin.ListCommittedOffsets(ctx, opts.CheckpointTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoint offsets: %w", err)
+	}
+
+	start, ok := starts.Lookup(opts.CheckpointTopic, 0)
+	if !ok || errors.Is(start.Err, kerr.UnknownTopicOrPartition) {
+		return nil, nil
+	}
+	end, ok := ends.Lookup(opts.CheckpointTopic, 0)
+	if !ok {
+		return nil, nil
+	}
+	if err := cmp.Or(start.Err, end.Err); err != nil {
+		return nil, fmt.Errorf("failed to list checkpoint offsets: %w", err)
+	}
+	if start.Offset >= end.Offset {
+		return nil, nil
+	}
+
+	// Control records are kept, so the offset of the last one read reaches
+	// the last stable offset even when it ends a transaction.
+	client, err := kgo.NewClient(slices.Concat(m.opts.Sink, []kgo.Opt{
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			opts.CheckpointTopic: {0: kgo.NewOffset().At(start.Offset)},
+		}),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.KeepControlRecords(),
+	})...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	checkpoints := map[topicPartition]int64{}
+	for next := start.Offset; next < end.Offset; {
+		fetches := client.PollFetches(rootCtx)
+		if err := fetches.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+		}
+
+		var decodeErr error
+		fetches.EachRecord(func(r *kgo.Record) {
+			next = r.Offset + 1
+			if r.Attrs.IsControl() || r.Offset >= end.Offset || decodeErr != nil {
+				return
+			}
+
+			var c checkpoint
+			if err := json.Unmarshal(r.Value, &c); err != nil {
+				decodeErr = fmt.Errorf("failed to decode checkpoint at offset %d: %w", r.Offset, err)
+				return
+			}
+			if c.ID == opts.TransactionalID {
+				checkpoints[topicPartition{Topic: c.Topic, Partition: c.Partition}] = c.Offset
+			}
+		})
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+	}
+
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	return checkpoints, nil
+}
+
+// createCheckpointTopic creates the checkpoint topic on the sink, unless it
+// exists.
+func (m *Mirror) createCheckpointTopic(rootCtx context.Context, client *kadm.Client) error {
+	ctx, cancel := context.WithTimeout(rootCtx, m.opts.Timeout)
+	defer cancel()
+
+	topic := m.opts.ExactlyOnce.CheckpointTopic
+	compact := "compact"
+	_, err := client.CreateTopic(ctx, 1, -1, map[string]*string{"cleanup.policy": &compact}, topic)
+	if err != nil && !errors.Is(err, kerr.TopicAlreadyExists) {
+		return fmt.Errorf("failed to create checkpoint topic %q: %w", topic, err)
+	}
+	return nil
+}
+
+// consumeCheckpointed returns the offsets to consume the source from with
+// checkpoints, the planned start offsets, so the first checkpoint of every
+// partition is where consuming started.
+func consumeCheckpointed(plan mirrorPlan) map[string]map[int32]kgo.Offset {
+	partitions := make(map[string]map[int32]kgo.Offset, len(plan.Create))
+	for _, pt := range plan.Create {
+		offsets := make(map[int32]kgo.Offset, len(pt.Offsets))
+		for _, p := range pt.Offsets {
+			offsets[p.Partition] = kgo.NewOffset().At(p.StartOffset)
+		}
+		partitions[pt.Topic] = offsets
+	}
+	return partitions
+}
+
+// transactor produces the records of an exactly-once mirror in transactions
+// of the sink, each committed along with the checkpoints of the partitions
+// whose records it holds. A nil *transactor is valid and produces records
+// outside of transactions.
+type transactor struct {
+	id      string
+	topic   string
+	sink    *sinkTarget
+	timeout time.Duration
+
+	// mu is held for reading while records are produced, and for writing
+	// while a transaction is committed, so a transaction holds exactly the
+	// records of its checkpoints.
+	mu sync.RWMutex
+	// nextMu guards next, written by the workers of every partition.
+	nextMu sync.Mutex
+	// next holds the next source offset of every partition, committed the
+	// ones of the last committed checkpoints.
+	next      map[topicPartition]int64
+	committed map[topicPartition]int64
+}
+
+// newTransactor returns the transactor of the sink of t, whose partitions
+// start at their planned offsets, and begins its first transaction. The
+// checkpoints resumed from aren't committed again until their partition
+// moves on.
+func newTransactor(opts ExactlyOnceOptions, t *sinkTarget, resumed map[topicPartition]int64, timeout time.Duration) (*transactor, error) {
+	if opts.TransactionalID == "" {
+		return nil, nil
+	}
+
+	x := &transactor{
+		id:        opts.TransactionalID,
+		topic:     opts.CheckpointTopic,
+		sink:      t,
+		timeout:   timeout,
+		next:      map[topicPartition]int64{},
+		committed: maps.Clone(resumed),
+	}
+	for _, pt := range t.plan.Create {
+		for _, p := range pt.Offsets {
+			x.next[topicPartition{Topic: pt.Topic, Partition: p.Partition}] = p.StartOffset
+		}
+	}
+
+	if err := t.client.BeginTransaction(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return x, nil
+}
+
+// produce calls produce, producing the record of tp at offset, within the
+// current transaction.
+func (x *transactor) produce(tp topicPartition, offset int64, produce func()) {
+	if x == nil {
+		produce()
+		return
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	produce()
+
+	x.nextMu.Lock()
+	defer x.nextMu.Unlock()
+	x.next[tp] = offset + 1
+}
+
+// run commits the current transaction every interval until ctx is done. A
+// failed commit drops the sink.
+func (x *transactor) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := x.commit(ctx); err != nil {
+				x.sink.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// commit commits the current transaction with the checkpoints of the
+// partitions that moved on since the last one, and begins the next. It isn't
+// canceled with ctx, as an interrupted commit leaves the transaction in an
+// unknown state.
+func (x *transactor) commit(rootCtx context.Context) error {
+	if x == nil {
+		return nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(rootCtx), x.timeout)
+	defer cancel()
+
+	client := x.sink.client
+	records := make([]*kgo.Record, 0, len(x.next))
+	for tp, offset := range x.next {
+		if committed, ok := x.committed[tp]; ok && committed == offset {
+			continue
+		}
+
+		c := checkpoint{ID: x.id, Topic: tp.Topic, Partition: tp.Partition, Offset: offset}
+		value, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("failed to encode checkpoint: %w", err)
+		}
+		records = append(records, &kgo.Record{Topic: x.topic, Partition: 0, Key: c.key(), Value: value})
+	}
+	if err := client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce checkpoints: %w", err)
+	}
+
+	if err := client.Flush(ctx); err != nil {
+		return fmt.Errorf("failed to flush records: %w", err)
+	}
+	// A record that failed to be produced would be missing from the
+	// transaction, while its offset is checkpointed.
+	if err := x.sink.failure(); err != nil {
+		return err
+	}
+
+	if err := client.EndTransaction(ctx, kgo.TryCommit); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	x.committed = maps.Clone(x.next)
+
+	if err := client.BeginTransaction(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return nil
+}
+
+// abort aborts the current transaction, so consumers of committed records
+// never see its records. A transaction without records is simply ended.
+func (x *transactor) abort() {
+	if x == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), x.timeout)
+	defer cancel()
+
+	client := x.sink.client
+	if err := client.AbortBufferedRecords(ctx); err != nil {
+		x.sink.log.Error("Failed to abort buffered records", slog.Any("error", err))
+	}
+	if err := client.EndTransaction(ctx, kgo.TryAbort); err != nil {
+		x.sink.log.Error("Failed to abort transaction", slog.Any("error", err))
+	}
+}
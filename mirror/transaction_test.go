@@ -0,0 +1,370 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestCheckExactlyOnce(t *testing.T) {
+	topics := []Topic{{Name: "orders"}}
+	exactlyOnce := ExactlyOnceOptions{TransactionalID: "kmir"}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "disabled", opts: Options{Topics: topics, PreserveCompression: true}},
+		{name: "enabled", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce}},
+		{name: "sinks", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce, Sinks: []Sink{{Name: "alice"}}}, wantErr: true},
+		{name: "preserve compression", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce, PreserveCompression: true}, wantErr: true},
+		{name: "latest per key", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce, Latest: LatestOptions{Topics: []string{"orders"}}}, wantErr: true},
+		{name: "groups", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce, Groups: []Group{{Source: "orders-service"}}}, wantErr: true},
+		{name: "offset map", opts: Options{Topics: topics, ExactlyOnce: exactlyOnce, OffsetMapFile: "offsets.json"}, wantErr: true},
+		{
+			name:    "mirrored to the default checkpoint topic",
+			opts:    Options{Topics: []Topic{{Name: "orders", Sink: "kmir-checkpoints"}}, ExactlyOnce: exactlyOnce},
+			wantErr: true,
+		},
+		{
+			name: "mirrored to the checkpoint topic",
+			opts: Options{
+				Topics:      []Topic{{Name: "checkpoints"}},
+				ExactlyOnce: ExactlyOnceOptions{TransactionalID: "kmir", CheckpointTopic: "checkpoints"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkExactlyOnce(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("checkExactlyOnce() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsumeCheckpointed(t *testing.T) {
+	plan := mirrorPlan{Create: []plannedTopic{{
+		Topic: "orders",
+		Offsets: []plannedPartition{
+			{Partition: 0, StartOffset: 3},
+			{Partition: 2, StartOffset: 0},
+		},
+	}}}
+
+	got := consumeCheckpointed(plan)
+	if len(got) != 1 || len(got["orders"]) != 2 {
+		t.Fatalf("consumeCheckpointed() = %v, want partitions 0 and 2 of orders", got)
+	}
+	for partition, want := range map[int32]int64{0: 3, 2: 0} {
+		if offset := got["orders"][partition].EpochOffset().Offset; offset != want {
+			t.Errorf("consumeCheckpointed() offset of partition %d = %d, want %d", partition, offset, want)
+		}
+	}
+}
+
+// committedValues returns the values of the records of partition 0 of topic
+// in committed transactions, or outside of any.
+func committedValues(t *testing.T, brokers []string, topic string) []string {
+	t.Helper()
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: {0: kgo.NewOffset().AtStart()}}),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.KeepControlRecords(),
+	)
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ends, err := kadm.NewClient(client).ListCommittedOffsets(ctx, topic)
+	if err != nil {
+		t.Fatalf("ListCommittedOffsets() error = %v", err)
+	}
+	end, _ := ends.Lookup(topic, 0)
+
+	values := []string{}
+	for next := int64(0); next < end.Offset; {
+		fetches := client.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			t.Fatalf("PollFetches() error = %v", err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			next = r.Offset + 1
+			if !r.Attrs.IsControl() && r.Offset < end.Offset {
+				values = append(values, string(r.Value))
+			}
+		})
+	}
+	return values
+}
+
+// waitCommitted waits until n records of topic are committed.
+func waitCommitted(t *testing.T, brokers []string, topic string, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		values := committedValues(t, brokers, topic)
+		if len(values) >= n || time.Now().After(deadline) {
+			return values
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestMirror_ExactlyOnce(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+
+	sinkCluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.ClusterID("kfake-exactly-once"))
+	if err != nil {
+		t.Fatalf("kfake.NewCluster() error = %v", err)
+	}
+	t.Cleanup(sinkCluster.Close)
+	sink := sinkCluster.ListenAddrs()
+
+	var failing atomic.Bool
+	failProducesWhile(sinkCluster, &failing)
+
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.ExactlyOnce = ExactlyOnceOptions{TransactionalID: "kmir-test", CommitInterval: 100 * time.Millisecond}
+
+	produceValues(t, source, "orders", "v0", "v1", "v2")
+	stop := startMirror(t, newTestMirror(t, opts))
+	waitCommitted(t, sink, "orders", 3)
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// A restarted mirror resumes from its checkpoints, keeping the records
+	// mirrored so far.
+	produceValues(t, source, "orders", "v3")
+	m := newTestMirror(t, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- m.Run(ctx) }()
+	waitCommitted(t, sink, "orders", 4)
+
+	// A mirror failing mid-transaction aborts it, and the next one mirrors
+	// its records again.
+	failing.Store(true)
+	produceValues(t, source, "orders", "v4")
+	select {
+	case err := <-errs:
+		var sinkErr *SinkWriteError
+		if !errors.As(err, &sinkErr) {
+			t.Errorf("Run() error = %v, want a *SinkWriteError", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("mirror did not stop on the failed produce")
+	}
+	failing.Store(false)
+
+	stop = startMirror(t, newTestMirror(t, opts))
+	got := waitCommitted(t, sink, "orders", 5)
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if want := []string{"v0", "v1", "v2", "v3", "v4"}; !slices.Equal(got, want) {
+		t.Errorf("committed records = %v, want %v", got, want)
+	}
+	if got := committedValues(t, sink, "orders"); len(got) != 5 {
+		t.Errorf("committed records after stopping = %v, want 5 records", got)
+	}
+}
+
+// produceTransaction produces values to topic in a transaction of the
+// producer with the given transactional ID, committed or aborted.
+func produceTransaction(t *testing.T, brokers []string, id, topic string, commit bool, values ...string) {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.TransactionalID(id))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.BeginTransaction(); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	for _, v := range values {
+		r := &kgo.Record{Topic: topic, Key: []byte(v[:1]), Value: []byte(v)}
+		if err := client.ProduceSync(ctx, r).FirstErr(); err != nil {
+			t.Fatalf("ProduceSync() error = %v", err)
+		}
+	}
+	if err := client.EndTransaction(ctx, kgo.TransactionEndTry(commit)); err != nil {
+		t.Fatalf("EndTransaction() error = %v", err)
+	}
+}
+
+func TestMirror_SourceTransactions(t *testing.T) {
+	skipShort(t)
+
+	tests := []struct {
+		name      string
+		isolation []kgo.Opt
+		want      []string
+	}{
+		{name: "read committed", want: []string{"c0", "c1", "c2"}},
+		{
+			name:      "read uncommitted",
+			isolation: []kgo.Opt{kgo.FetchIsolationLevel(kgo.ReadUncommitted())},
+			want:      []string{"a0", "c0", "c1", "c2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeCluster(t, 1, "orders")
+			sink := newFakeCluster(t, 1)
+
+			// Every transaction ends with a marker taking an offset of its
+			// own, so the source partition ends with one.
+			produceTransaction(t, source, "producer", "orders", true, "c0", "c1")
+			produceTransaction(t, source, "producer", "orders", false, "a0")
+			produceTransaction(t, source, "producer", "orders", true, "c2")
+
+			opts := testOptions(t, source, sink, "orders@-2")
+			opts.Source = append(opts.Source, tt.isolation...)
+			stop := startMirror(t, newTestMirror(t, opts))
+
+			got := recordValues(consumeRecords(t, sink, len(tt.want), "orders"))
+			if err := stop(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mirrored records = %v, want %v", got, tt.want)
+			}
+			if end := endOffset(t, sink, "orders"); end != int64(len(tt.want)) {
+				t.Errorf("end offset of orders on the sink = %d, want %d, markers were mirrored", end, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMirror_LatestAfterTransactionMarker(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "state")
+	sink := newFakeCluster(t, 1)
+	produceTransaction(t, source, "producer", "state", true, "a1", "b1", "a2")
+
+	opts := testOptions(t, source, sink, "state@-2")
+	opts.Latest = LatestOptions{Topics: []string{"state"}}
+
+	// The partition ends with the commit marker rather than a record, yet
+	// the mirror stops once the latest records are produced.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := newTestMirror(t, opts).Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := recordValues(consumeRecords(t, sink, 2, "state")); !slices.Equal(got, []string{"a2", "b1"}) {
+		t.Errorf("mirrored records = %v, want [a2 b1]", got)
+	}
+}
+
+func TestMirror_ExactlyOnceFencesPreviousMirror(t *testing.T) {
+	skipShort(t)
+
+	source := newFakeCluster(t, 1, "orders")
+	sink := newFakeCluster(t, 1, "previous")
+
+	// A previous mirror of the same transactional ID, still running, has a
+	// transaction open.
+	previous, err := kgo.NewClient(kgo.SeedBrokers(sink...), kgo.TransactionalID("kmir-test"))
+	if err != nil {
+		t.Fatalf("kgo.NewClient() error = %v", err)
+	}
+	defer previous.Close()
+
+	ctx := context.Background()
+	if err := previous.BeginTransaction(); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	if err := previous.ProduceSync(ctx, &kgo.Record{Topic: "previous", Value: []byte("v0")}).FirstErr(); err != nil {
+		t.Fatalf("ProduceSync() error = %v", err)
+	}
+
+	// The previous mirror commits its transaction as soon as the checkpoints
+	// are read, which it only fails to if it was fenced before.
+	var once sync.Once
+	committed := make(chan error, 1)
+	onMessage(t, "Reading checkpoints", func() {
+		once.Do(func() { committed <- previous.EndTransaction(ctx, kgo.TryCommit) })
+	})
+
+	opts := testOptions(t, source, sink, "orders@-2")
+	opts.ExactlyOnce = ExactlyOnceOptions{TransactionalID: "kmir-test", CommitInterval: time.Minute}
+	stop := startMirror(t, newTestMirror(t, opts))
+	waitForTopic(t, sink, "orders", 1)
+
+	if err := <-committed; err == nil {
+		t.Error("EndTransaction() of the previous mirror error = nil, want it fenced")
+	}
+	if got := committedValues(t, sink, "previous"); len(got) != 0 {
+		t.Errorf("committed records of the previous mirror = %v, want none", got)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
+
+// onMessage calls fn whenever a message is logged through the default logger
+// until the end of the test.
+func onMessage(t *testing.T, msg string, fn func()) {
+	t.Helper()
+
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	handler := slog.NewTextHandler(os.Stderr, nil)
+	slog.SetDefault(slog.New(messageHandler{Handler: handler, msg: msg, fn: fn}))
+}
+
+type messageHandler struct {
+	slog.Handler
+	msg string
+	fn  func()
+}
+
+func (h messageHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Message == h.msg {
+		h.fn()
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h messageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return messageHandler{Handler: h.Handler.WithAttrs(attrs), msg: h.msg, fn: h.fn}
+}
+
+func (h messageHandler) WithGroup(name string) slog.Handler {
+	return messageHandler{Handler: h.Handler.WithGroup(name), msg: h.msg, fn: h.fn}
+}
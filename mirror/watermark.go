@@ -0,0 +1,77 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Watermark holds the log start and end offsets of a partition.
+type Watermark struct {
+	Start int64
+	End   int64
+}
+
+// Resolve turns a topic offset (where -1 is the end and -2 or lower is the
+// start) into an absolute offset within the partition.
+func (wm Watermark) Resolve(offset int64) int64 {
+	switch {
+	case offset == -1:
+		return wm.End
+	case offset < -1:
+		return wm.Start
+	default:
+		return offset
+	}
+}
+
+// ListWatermarks returns the watermarks of every partition of topics, by
+// topic and partition.
+func ListWatermarks(ctx context.Context, client *kadm.Client, topics ...string) (map[string]map[int32]Watermark, error) {
+	return listWatermarks(ctx, client, client.ListEndOffsets, topics)
+}
+
+// ListCommittedWatermarks is ListWatermarks, except that partitions end at
+// their last stable offset rather than their high watermark: a consumer of
+// committed records can't read past a transaction still open.
+func ListCommittedWatermarks(ctx context.Context, client *kadm.Client, topics ...string) (map[string]map[int32]Watermark, error) {
+	return listWatermarks(ctx, client, client.ListCommittedOffsets, topics)
+}
+
+// ReadsCommitted reports whether client only fetches committed records.
+func ReadsCommitted(client *kgo.Client) bool {
+	// The level of kgo.ReadCommitted, which isn't comparable otherwise.
+	return client.OptValue(kgo.FetchIsolationLevel) == int8(1)
+}
+
+func listWatermarks(ctx context.Context, client *kadm.Client, listEnds func(context.Context, ...string) (kadm.ListedOffsets, error), topics []string) (map[string]map[int32]Watermark, error) {
+	startOffsets, err := client.ListStartOffsets(ctx, topics...)
+	if err == nil {
+		err = startOffsets.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list start offsets: %w", err)
+	}
+
+	endOffsets, err := listEnds(ctx, topics...)
+	if err == nil {
+		err = endOffsets.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list end offsets: %w", err)
+	}
+
+	watermarks := make(map[string]map[int32]Watermark, len(endOffsets))
+	endOffsets.Each(func(o kadm.ListedOffset) {
+		if watermarks[o.Topic] == nil {
+			watermarks[o.Topic] = map[int32]Watermark{}
+		}
+
+		start, _ := startOffsets.Lookup(o.Topic, o.Partition)
+		watermarks[o.Topic][o.Partition] = Watermark{Start: start.Offset, End: o.Offset}
+	})
+
+	return watermarks, nil
+}
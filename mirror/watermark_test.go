@@ -0,0 +1,57 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestWatermark_Resolve(t *testing.T) {
+	wm := Watermark{Start: 10, End: 50}
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   int64
+	}{
+		{"end", -1, 50},
+		{"start", -2, 10},
+		{"lower than start marker", -5, 10},
+		{"absolute", 25, 25},
+		{"zero", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wm.Resolve(tt.offset); got != tt.want {
+				t.Errorf("Resolve(%d) = %d, want %d", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadsCommitted(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []kgo.Opt
+		want bool
+	}{
+		{"default", nil, false},
+		{"read committed", []kgo.Opt{kgo.FetchIsolationLevel(kgo.ReadCommitted())}, true},
+		{"read uncommitted", []kgo.Opt{kgo.FetchIsolationLevel(kgo.ReadUncommitted())}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := kgo.NewClient(tt.opts...)
+			if err != nil {
+				t.Fatalf("kgo.NewClient() error = %v", err)
+			}
+			defer client.Close()
+
+			if got := ReadsCommitted(client); got != tt.want {
+				t.Errorf("ReadsCommitted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mortezaPRK/kmir/mirror"
+)
+
+const (
+	// progressInterval is how often the progress table is redrawn on a terminal.
+	progressInterval = time.Second
+	// progressLogInterval is how often progress is logged without a terminal.
+	progressLogInterval = 10 * time.Second
+)
+
+// progressReporter returns the function reporting the mirror progress. On a
+// terminal the table is redrawn in place, otherwise a log line per partition
+// is emitted.
+func progressReporter(w io.Writer, tty bool) func([]mirror.PartitionProgress) {
+	if tty {
+		return func(progress []mirror.PartitionProgress) {
+			_, _ = fmt.Fprint(w, "\x1b[H\x1b[J")
+			_ = renderProgress(w, progress)
+		}
+	}
+
+	return func(progress []mirror.PartitionProgress) {
+		for _, pp := range progress {
+			slog.LogAttrs(context.Background(), slog.LevelInfo, "Mirror progress",
+				slog.String("topic", pp.Topic),
+				slog.Int("partition", int(pp.Partition)),
+				slog.Int64("offset", pp.Offset),
+				slog.Int64("high_watermark", pp.HighWatermark),
+				slog.String("percent", fmt.Sprintf("%.1f", pp.Percent())),
+				slog.String("rate", fmt.Sprintf("%.1f/s", pp.Rate)),
+				slog.String("eta", formatETA(pp.ETA())),
+			)
+		}
+	}
+}
+
+func renderProgress(w io.Writer, progress []mirror.PartitionProgress) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "TOPIC\tPARTITION\tOFFSET\tHIGH-WATERMARK\tDONE\tRATE\tETA\t")
+	for _, pp := range progress {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\t%.1f/s\t%s\t\n",
+			pp.Topic, pp.Partition, pp.Offset, pp.HighWatermark, pp.Percent(), pp.Rate, formatETA(pp.ETA()))
+	}
+	return tw.Flush()
+}
+
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "-"
+	}
+	return eta.Round(time.Second).String()
+}
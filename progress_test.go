@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mortezaPRK/kmir/mirror"
+)
+
+func TestRenderProgress(t *testing.T) {
+	progress := []mirror.PartitionProgress{
+		{Topic: "orders", Partition: 0, Start: 50, Offset: 50, HighWatermark: 150},
+		{Topic: "orders", Partition: 1, Start: 0, Offset: 20, HighWatermark: 120, Rate: 10},
+	}
+
+	var buf bytes.Buffer
+	if err := renderProgress(&buf, progress); err != nil {
+		t.Fatalf("renderProgress() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("renderProgress() printed %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[2], "16.7%") || !strings.Contains(lines[2], "10s") {
+		t.Errorf("renderProgress() partition 1 line = %q, want 16.7%% done and a 10s ETA", lines[2])
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		eta  time.Duration
+		want string
+	}{
+		{-1, "-"},
+		{0, "0s"},
+		{1500 * time.Millisecond, "2s"},
+	}
+
+	for _, tt := range tests {
+		if got := formatETA(tt.eta); got != tt.want {
+			t.Errorf("formatETA(%v) = %q, want %q", tt.eta, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"time"
 
+	"github.com/mortezaPRK/kmir/mirror"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/kversion"
 )
@@ -24,25 +25,37 @@ type TLS struct {
 
 // BrokerOptions defines the configuration for a Kafka broker.
 type BrokerOptions struct {
-	Brokers []string      `long:"brokers" env:"BROKERS" env-delim:"," description:"Comma-separated list of Kafka brokers" required:"true"`
+	Brokers []string      `long:"brokers" env:"BROKERS" env-delim:"," description:"Comma-separated list of Kafka brokers"`
 	TLS     TLS           `group:"TLS" namespace:"tls" env-namespace:"TLS"`
 	Sasl    Sasl          `group:"SASL" namespace:"sasl" env-namespace:"SASL"`
 	Timeout time.Duration `long:"timeout" env:"TIMEOUT" description:"Timeout for Kafka" default:"10s"`
 }
 
-// TopicOption defines the configuration for a topic.
-type TopicOption struct {
-	Offset             int64
-	PerPartitionOffset map[int32]int64
+// LogOptions defines the logging configuration.
+type LogOptions struct {
+	Level          string  `long:"level" env:"LEVEL" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"info" description:"Log level"`
+	Format         string  `long:"format" env:"FORMAT" choice:"text" choice:"json" default:"text" description:"Log format"`
+	ClientLevel    string  `long:"client-level" env:"CLIENT_LEVEL" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"warn" description:"Minimum level of logs from the Kafka clients"`
+	RecordSampling float64 `long:"record-sampling" env:"RECORD_SAMPLING" description:"Fraction of mirrored records (0 to 1) logged at debug level" default:"0"`
 }
 
-func (to TopicOption) OffsetOf(partition int32) (int64, bool) {
-	if to.PerPartitionOffset == nil {
-		return to.Offset, true
-	}
+// ProducerOptions defines how records are produced to the sink. The defaults
+// are the ones of the Kafka client.
+type ProducerOptions struct {
+	Compression        string        `long:"compression" env:"COMPRESSION" choice:"none" choice:"gzip" choice:"snappy" choice:"lz4" choice:"zstd" default:"snappy" description:"Compression codec of produced batches"`
+	Acks               string        `long:"acks" env:"ACKS" choice:"all" choice:"leader" choice:"none" default:"all" description:"Acknowledgements required from the sink brokers; anything but all requires --sink-disable-idempotence"`
+	DisableIdempotence bool          `long:"disable-idempotence" env:"DISABLE_IDEMPOTENCE" description:"Disable idempotent writes"`
+	Linger             time.Duration `long:"linger" env:"LINGER" default:"10ms" description:"How long a partition waits for more records before its batch is sent"`
+	MaxBatchBytes      int32         `long:"max-batch-bytes" env:"MAX_BATCH_BYTES" default:"1000012" description:"Maximum size of a produced batch"`
+	MaxBufferedRecords int           `long:"max-buffered-records" env:"MAX_BUFFERED_RECORDS" default:"10000" description:"Maximum number of records waiting to be produced before the mirror blocks"`
+}
 
-	offset, ok := to.PerPartitionOffset[partition]
-	return offset, ok
+// FetchOptions defines how records are fetched from the source. The
+// defaults are the ones of the Kafka client, but for the isolation level.
+type FetchOptions struct {
+	MaxBytes  int32         `long:"fetch-max-bytes" env:"FETCH_MAX_BYTES" default:"52428800" description:"Maximum bytes returned by a fetch request"`
+	MaxWait   time.Duration `long:"fetch-max-wait" env:"FETCH_MAX_WAIT" default:"5s" description:"Maximum time the source brokers wait for records before answering a fetch"`
+	Isolation string        `long:"isolation" env:"ISOLATION" choice:"read_committed" choice:"read_uncommitted" default:"read_committed" description:"Which records of transactions are read: only committed ones, or aborted and open ones too"`
 }
 
 // Options defines the command line options for the application.
@@ -51,6 +64,147 @@ type Options struct {
 	Sink         BrokerOptions `group:"Sink" namespace:"sink" env-namespace:"SINK"`
 	ClientID     string        `long:"client-id" env:"CLIENT_ID" description:"Client ID" required:"true"`
 	KafkaVersion string        `long:"kafka-version" env:"KAFKA_VERSION" description:"Kafka version" required:"true"`
+	Log          LogOptions    `group:"Logging" namespace:"log" env-namespace:"LOG"`
+
+	Producer ProducerOptions `group:"Sink producer" namespace:"sink" env-namespace:"SINK"`
+	Fetch    FetchOptions    `group:"Source fetch" namespace:"source" env-namespace:"SOURCE"`
+
+	Mirror  MirrorCommand  `command:"mirror" description:"Mirror topics from source to sink (default command)"`
+	Topics  TopicsCommand  `command:"topics" description:"List or describe topics on the source or sink"`
+	Offsets OffsetsCommand `command:"offsets" subcommands-optional:"true" description:"Show watermarks of topics, or translate source offsets to sink offsets"`
+	Diff    DiffCommand    `command:"diff" description:"Compare topics between source and sink"`
+	Capture CaptureCommand `command:"capture" description:"Capture records from source topics into a file"`
+	Replay  ReplayCommand  `command:"replay" description:"Produce captured records into the sink"`
+}
+
+// MirrorCommand defines the options of the mirror command.
+type MirrorCommand struct {
+	DryRun    bool     `long:"dry-run" env:"DRY_RUN" description:"Print what would be done without changing the sink or consuming records"`
+	Format    string   `long:"format" env:"FORMAT" choice:"table" choice:"json" default:"table" description:"Output format of the dry-run plan"`
+	Yes       bool     `long:"yes" short:"y" env:"YES" description:"Delete existing sink topics without asking for confirmation"`
+	AllowSink []string `long:"allow-sink" env:"ALLOW_SINK" env-delim:"," description:"Sink broker host, host:port or cluster ID that may be written to; if set, any other sink is refused"`
+
+	Progress    bool   `long:"progress" env:"PROGRESS" description:"Show the progress of every partition, redrawn in place on a terminal and logged periodically otherwise"`
+	MetricsAddr string `long:"metrics-addr" env:"METRICS_ADDR" description:"Address to serve Prometheus metrics on, e.g. :9090; disabled if empty"`
+	ControlAddr string `long:"control-addr" env:"CONTROL_ADDR" description:"Address to serve the pause/resume endpoints on, e.g. :9091; disabled if empty"`
+
+	SinkReplicationFactor string `long:"sink-replication-factor" env:"SINK_REPLICATION_FACTOR" description:"Replication factor of the sink topics, a number or source to copy it from the source topic, clamped to the number of sink brokers; the sink default if empty"`
+	SinkMinInsyncReplicas int16  `long:"sink-min-insync-replicas" env:"SINK_MIN_INSYNC_REPLICAS" description:"min.insync.replicas of the sink topics, clamped to their replication factor; copied from the source topic if 0 and --sink-replication-factor is set"`
+
+	SinkPartitions       map[string]int32 `long:"sink-partitions" description:"Number of partitions of a sink topic, as topic:partitions, instead of the one of the source topic (can be repeated)"`
+	SinkPartitionRouting string           `long:"sink-partition-routing" env:"SINK_PARTITION_ROUTING" choice:"modulo" choice:"murmur2" choice:"round-robin" default:"modulo" description:"How records of topics with --sink-partitions are routed: source partition modulo sink partitions, key hash like the Kafka partitioner, or round-robin"`
+
+	PartitionQueue int `long:"partition-queue" env:"PARTITION_QUEUE" default:"4" description:"Fetched batches queued per partition before fetching of the partition is paused"`
+
+	PreserveCompression bool `long:"preserve-compression" env:"PRESERVE_COMPRESSION" description:"Produce records with the compression codec of their source batch instead of --sink-compression"`
+
+	Groups            []string      `long:"group" env:"GROUPS" env-delim:"," description:"Source consumer group whose committed offsets are translated and committed on the sink, as group or source-group:sink-group (can be repeated)"`
+	GroupSyncInterval time.Duration `long:"group-sync-interval" env:"GROUP_SYNC_INTERVAL" default:"10s" description:"How often consumer group offsets are synced, besides once the mirror stops"`
+
+	ACLs              bool     `long:"acls" env:"ACLS" description:"Recreate on the sink the source ACLs of the mirrored topics and consumer groups"`
+	PrincipalRewrites []string `long:"principal-rewrite" env:"PRINCIPAL_REWRITES" env-delim:"," description:"Principal of mirrored ACLs to replace, as from=to, e.g. User:orders-service=User:local (can be repeated)"`
+
+	OffsetMap         string        `long:"offset-map" env:"OFFSET_MAP" description:"File to keep the source to sink offset map of mirrored records in, for kmir offsets translate; disabled if empty"`
+	OffsetMapInterval time.Duration `long:"offset-map-interval" env:"OFFSET_MAP_INTERVAL" default:"10s" description:"How often the offset map is written, besides once the mirror stops"`
+
+	Sinks string `long:"sinks" env:"SINKS" description:"JSON file of several sink clusters to mirror into at once, each with its name, brokers, tls, sasl and optionally the topics produced to it, instead of --sink-brokers"`
+
+	Sources         string `long:"sources" env:"SOURCES" description:"JSON file of several source clusters to mirror into the sink, each with its name, brokers, tls, sasl, topics and groups, instead of --source-brokers and topic arguments"`
+	TopicCollisions string `long:"topic-collisions" env:"TOPIC_COLLISIONS" choice:"fail" choice:"prefix" default:"fail" description:"What to do with topics of several --sources mirrored to the same sink topic: fail, or prefix them with the name of their source"`
+
+	ReverseTopics []string `long:"reverse-topic" env:"REVERSE_TOPICS" env-delim:"," description:"Topic of the sink to mirror back to the source, as a topic argument, syncing both clusters in both directions without mirroring back what came from the other side (can be repeated)"`
+	SourceName    string   `long:"source-name" env:"SOURCE_NAME" default:"source" description:"Name of the source cluster in the kmir-source header of the records mirrored from it, with --reverse-topic"`
+	SinkName      string   `long:"sink-name" env:"SINK_NAME" default:"sink" description:"Name of the sink cluster in the kmir-source header of the records mirrored from it, with --reverse-topic"`
+
+	Sample map[string]string `long:"sample" description:"Mirror a sample of a topic, as topic:nth=N, topic:percent=P or topic:key=P; use * as topic for all topics (can be repeated)"`
+
+	Throttle    ThrottleOptions    `group:"Throttling"`
+	Latest      LatestOptions      `group:"Latest per key"`
+	ExactlyOnce ExactlyOnceOptions `group:"Exactly once"`
+}
+
+// ExactlyOnceOptions defines the exactly-once mode of the mirror command. Its
+// fields are those of mirror.ExactlyOnceOptions, which it is converted to.
+type ExactlyOnceOptions struct {
+	TransactionalID string        `long:"transactional-id" env:"TRANSACTIONAL_ID" description:"Mirror exactly once in transactions of the sink producer with this ID, resuming from the last committed checkpoint when restarted with the same ID; disabled if empty"`
+	CheckpointTopic string        `long:"checkpoint-topic" env:"CHECKPOINT_TOPIC" default:"kmir-checkpoints" description:"Sink topic holding the source offsets committed with every transaction, created if missing"`
+	CommitInterval  time.Duration `long:"commit-interval" env:"COMMIT_INTERVAL" default:"1s" description:"How often transactions are committed"`
+}
+
+// LatestOptions defines how topics mirrored with only the latest record per
+// key are read. Its fields are those of mirror.LatestOptions, which it is
+// converted to.
+type LatestOptions struct {
+	Topics   []string `long:"latest-per-key" env:"LATEST_PER_KEY" env-delim:"," description:"Topic to mirror with only the latest record per key, read up to the high watermark at startup (can be repeated)"`
+	Store    string   `long:"latest-store" env:"LATEST_STORE" choice:"memory" choice:"disk" default:"memory" description:"Where the latest record per key is kept until it is produced"`
+	StoreDir string   `long:"latest-store-dir" env:"LATEST_STORE_DIR" description:"Directory of the disk store, the system temporary directory if empty"`
+	Follow   bool     `long:"latest-follow" env:"LATEST_FOLLOW" description:"Keep mirroring new records of the latest-per-key topics once their latest records are produced"`
+}
+
+// ThrottleOptions defines the throughput limits of the mirror command. Its
+// fields are those of mirror.ThrottleOptions, which it is converted to.
+type ThrottleOptions struct {
+	MaxRecordsPerSec      float64            `long:"max-records-per-sec" env:"MAX_RECORDS_PER_SEC" description:"Maximum records mirrored per second across all topics, 0 for no limit"`
+	MaxBytesPerSec        float64            `long:"max-bytes-per-sec" env:"MAX_BYTES_PER_SEC" description:"Maximum key and value bytes mirrored per second across all topics, 0 for no limit"`
+	TopicMaxRecordsPerSec map[string]float64 `long:"topic-max-records-per-sec" description:"Maximum records mirrored per second for a topic, as topic:limit (can be repeated)"`
+	TopicMaxBytesPerSec   map[string]float64 `long:"topic-max-bytes-per-sec" description:"Maximum key and value bytes mirrored per second for a topic, as topic:limit (can be repeated)"`
+}
+
+// SourceCluster is a source cluster of the mirror command, as listed in the
+// --sources file.
+type SourceCluster struct {
+	Name    string   `json:"name"`
+	Brokers []string `json:"brokers"`
+	TLS     TLS      `json:"tls"`
+	Sasl    Sasl     `json:"sasl"`
+	// Topics and Groups are the arguments of the mirror command and its
+	// --group options.
+	Topics []string `json:"topics"`
+	Groups []string `json:"groups"`
+}
+
+// SinkCluster is a sink cluster of the mirror command, as listed in the
+// --sinks file.
+type SinkCluster struct {
+	Name    string   `json:"name"`
+	Brokers []string `json:"brokers"`
+	TLS     TLS      `json:"tls"`
+	Sasl    Sasl     `json:"sasl"`
+	// Topics are the mirrored topics produced to the sink, every topic if
+	// empty.
+	Topics []string `json:"topics"`
+}
+
+// TopicsCommand defines the options of the topics command.
+type TopicsCommand struct {
+	Side     string `long:"side" env:"TOPICS_SIDE" choice:"source" choice:"sink" default:"source" description:"Cluster to inspect"`
+	Internal bool   `long:"internal" description:"Include internal topics when listing"`
+}
+
+// OffsetsCommand defines the options of the offsets command.
+type OffsetsCommand struct {
+	Translate OffsetsTranslateCommand `command:"translate" description:"Translate a source offset to its sink offset: translate TOPIC PARTITION OFFSET"`
+}
+
+// OffsetsTranslateCommand defines the options of the offsets translate
+// command.
+type OffsetsTranslateCommand struct {
+	Map string `long:"map" env:"OFFSET_MAP" required:"true" description:"Offset map written by the mirror command with --offset-map"`
+}
+
+// DiffCommand defines the options of the diff command.
+type DiffCommand struct{}
+
+// CaptureCommand defines the options of the capture command.
+type CaptureCommand struct {
+	Output     string `long:"output" short:"o" env:"CAPTURE_OUTPUT" description:"File to write records to, - for stdout" default:"-"`
+	MaxRecords int    `long:"max-records" env:"CAPTURE_MAX_RECORDS" description:"Stop after capturing this many records, 0 for no limit"`
+	Follow     bool   `long:"follow" env:"CAPTURE_FOLLOW" description:"Keep capturing after reaching the high watermark"`
+}
+
+// ReplayCommand defines the options of the replay command.
+type ReplayCommand struct {
+	Input string `long:"input" short:"i" env:"REPLAY_INPUT" description:"File to read records from, - for stdin" default:"-"`
 }
 
 // Config defines the configuration for the whole application.
@@ -59,7 +213,21 @@ type Config struct {
 	Source       []kgo.Opt
 	ClientID     string
 	KafkaVersion *kversion.Versions
-	Topics       map[string]TopicOption
-	TopicNames   []string
+	Topics       []mirror.Topic
 	Timeout      time.Duration
+
+	// SourceTuning and SinkTuning hold the options of every client of their
+	// side besides its brokers, TLS and SASL, for the clusters of the
+	// --sources and --sinks files.
+	SourceTuning  []kgo.Opt
+	SourceTimeout time.Duration
+	SinkTuning    []kgo.Opt
+	SinkTimeout   time.Duration
+
+	// FetchTuning and ProducerTuning hold the fetch and producer options of
+	// clients both consumed and produced to, with --reverse-topic.
+	FetchTuning    []kgo.Opt
+	ProducerTuning []kgo.Opt
+
+	LogRecordSampling float64
 }